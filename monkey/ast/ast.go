@@ -12,6 +12,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
 }
 
 // Statement subtype
@@ -39,6 +40,14 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+// Pos interface method
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 // String interface method
 func (p *Program) String() string {
 	var out bytes.Buffer
@@ -63,6 +72,11 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+// Pos interface method
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos()
+}
+
 // String interface method
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
@@ -90,6 +104,11 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
 
+// Pos interface method
+func (rs *ReturnStatement) Pos() token.Position {
+	return rs.Token.Pos()
+}
+
 // String interface method
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
@@ -115,6 +134,11 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
 
+// Pos interface method
+func (es *ExpressionStatement) Pos() token.Position {
+	return es.Token.Pos()
+}
+
 // String interface method
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
@@ -137,6 +161,11 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+// Pos interface method
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos()
+}
+
 // String interface method
 func (i *Identifier) String() string {
 	return i.Value
@@ -156,11 +185,40 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
 
+// Pos interface method
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos()
+}
+
 // String interface method
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// FloatLiteral struct
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+// expressionNode interface method
+func (fl *FloatLiteral) expressionNode() {}
+
+// TokenLiteral interface method
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+// Pos interface method
+func (fl *FloatLiteral) Pos() token.Position {
+	return fl.Token.Pos()
+}
+
+// String interface method
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 // StringLiteral struct
 type StringLiteral struct {
 	Token token.Token
@@ -175,6 +233,11 @@ func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Token.Literal
 }
 
+// Pos interface method
+func (sl *StringLiteral) Pos() token.Position {
+	return sl.Token.Pos()
+}
+
 // String interface method
 func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
@@ -194,6 +257,11 @@ func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
 
+// Pos interface method
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos()
+}
+
 // String interface method
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
@@ -219,6 +287,11 @@ func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+// Pos interface method
+func (ie *InfixExpression) Pos() token.Position {
+	return ie.Token.Pos()
+}
+
 // String interface method
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
@@ -230,6 +303,35 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// AssignExpression struct
+type AssignExpression struct {
+	Token    token.Token // the assignment operator token
+	Target   Expression  // an *Identifier or an *IndexExpression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
+// TokenLiteral interface method
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+
+// Pos interface method
+func (ae *AssignExpression) Pos() token.Position {
+	return ae.Token.Pos()
+}
+
+// String interface method
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	return out.String()
+}
+
 // Boolean struct
 type Boolean struct {
 	Token token.Token
@@ -243,6 +345,11 @@ func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
 
+// Pos interface method
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos()
+}
+
 // String interface method
 func (b *Boolean) String() string {
 	return b.Token.Literal
@@ -263,6 +370,11 @@ func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+// Pos interface method
+func (ie *IfExpression) Pos() token.Position {
+	return ie.Token.Pos()
+}
+
 // String interface method
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
@@ -278,6 +390,46 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// MatchExpression struct
+type MatchExpression struct {
+	Token token.Token // the 'match' token
+	Value Expression
+	Arms  []*MatchArm
+}
+
+// MatchArm struct is one pattern => body case of a match
+type MatchArm struct {
+	Pattern Expression
+	Body    Expression
+}
+
+func (me *MatchExpression) expressionNode() {}
+
+// TokenLiteral interface method
+func (me *MatchExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+// Pos interface method
+func (me *MatchExpression) Pos() token.Position {
+	return me.Token.Pos()
+}
+
+// String interface method
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.Pattern.String()+" => "+arm.Body.String())
+	}
+	out.WriteString("match (")
+	out.WriteString(me.Value.String())
+	out.WriteString(") {")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
 // BlockStatement struct
 type BlockStatement struct {
 	Token      token.Token
@@ -291,6 +443,11 @@ func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
 
+// Pos interface method
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos()
+}
+
 // String interface method
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
@@ -300,6 +457,113 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
+// WhileStatement struct
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+// TokenLiteral interface method
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+
+// Pos interface method
+func (ws *WhileStatement) Pos() token.Position {
+	return ws.Token.Pos()
+}
+
+// String interface method
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while")
+	out.WriteString(" ")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+	return out.String()
+}
+
+// ForStatement struct
+type ForStatement struct {
+	Token    token.Token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+
+// TokenLiteral interface method
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+// Pos interface method
+func (fs *ForStatement) Pos() token.Position {
+	return fs.Token.Pos()
+}
+
+// String interface method
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for")
+	out.WriteString(" (")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+
+// BreakStatement struct
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode() {}
+
+// TokenLiteral interface method
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+
+// Pos interface method
+func (bs *BreakStatement) Pos() token.Position {
+	return bs.Token.Pos()
+}
+
+// String interface method
+func (bs *BreakStatement) String() string {
+	return bs.TokenLiteral() + ";"
+}
+
+// ContinueStatement struct
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode() {}
+
+// TokenLiteral interface method
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+
+// Pos interface method
+func (cs *ContinueStatement) Pos() token.Position {
+	return cs.Token.Pos()
+}
+
+// String interface method
+func (cs *ContinueStatement) String() string {
+	return cs.TokenLiteral() + ";"
+}
+
 // FunctionLiteral struct
 type FunctionLiteral struct {
 	Token      token.Token
@@ -315,6 +579,11 @@ func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
 
+// Pos interface method
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos()
+}
+
 // String interface method
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
@@ -333,6 +602,40 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// MacroLiteral struct
+type MacroLiteral struct {
+	Token      token.Token // the 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode() {}
+
+// TokenLiteral interface method
+func (ml *MacroLiteral) TokenLiteral() string {
+	return ml.Token.Literal
+}
+
+// Pos interface method
+func (ml *MacroLiteral) Pos() token.Position {
+	return ml.Token.Pos()
+}
+
+// String interface method
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(ml.Body.String())
+	return out.String()
+}
+
 // CallExpression struct
 type CallExpression struct {
 	Token     token.Token
@@ -347,6 +650,11 @@ func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
 
+// Pos interface method
+func (ce *CallExpression) Pos() token.Position {
+	return ce.Token.Pos()
+}
+
 // String interface method
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
@@ -374,6 +682,11 @@ func (al *ArrayLiteral) TokenLiteral() string {
 	return al.Token.Literal
 }
 
+// Pos interface method
+func (al *ArrayLiteral) Pos() token.Position {
+	return al.Token.Pos()
+}
+
 // String interface method
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
@@ -401,6 +714,11 @@ func (ie *IndexExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+// Pos interface method
+func (ie *IndexExpression) Pos() token.Position {
+	return ie.Token.Pos()
+}
+
 // String interface method
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
@@ -425,6 +743,11 @@ func (hl *HashLiteral) TokenLiteral() string {
 	return hl.Token.Literal
 }
 
+// Pos interface method
+func (hl *HashLiteral) Pos() token.Position {
+	return hl.Token.Pos()
+}
+
 // String interface method
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
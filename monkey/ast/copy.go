@@ -0,0 +1,210 @@
+// Package ast ast/copy.go
+package ast
+
+// Copy returns a deep copy of the tree below and including node, which
+// can be modified without changing node
+func Copy(node Node) Node {
+	switch node := node.(type) {
+
+	case *Program:
+		return &Program{Statements: copyStatements(node.Statements)}
+
+	case *LetStatement:
+		return &LetStatement{
+			Token: node.Token,
+			Name:  copyIdentifier(node.Name),
+			Value: copyExpression(node.Value),
+		}
+
+	case *ReturnStatement:
+		return &ReturnStatement{Token: node.Token, ReturnValue: copyExpression(node.ReturnValue)}
+
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: node.Token, Expression: copyExpression(node.Expression)}
+
+	case *BlockStatement:
+		return copyBlock(node)
+
+	case *WhileStatement:
+		return &WhileStatement{
+			Token:     node.Token,
+			Condition: copyExpression(node.Condition),
+			Body:      copyBlock(node.Body),
+		}
+
+	case *ForStatement:
+		return &ForStatement{
+			Token:    node.Token,
+			Variable: copyIdentifier(node.Variable),
+			Iterable: copyExpression(node.Iterable),
+			Body:     copyBlock(node.Body),
+		}
+
+	case *BreakStatement:
+		copied := *node
+		return &copied
+
+	case *ContinueStatement:
+		copied := *node
+		return &copied
+
+	case *Identifier:
+		return copyIdentifier(node)
+
+	case *IntegerLiteral:
+		copied := *node
+		return &copied
+
+	case *FloatLiteral:
+		copied := *node
+		return &copied
+
+	case *StringLiteral:
+		copied := *node
+		return &copied
+
+	case *Boolean:
+		copied := *node
+		return &copied
+
+	case *PrefixExpression:
+		return &PrefixExpression{
+			Token:    node.Token,
+			Operator: node.Operator,
+			Right:    copyExpression(node.Right),
+		}
+
+	case *InfixExpression:
+		return &InfixExpression{
+			Token:    node.Token,
+			Left:     copyExpression(node.Left),
+			Operator: node.Operator,
+			Right:    copyExpression(node.Right),
+		}
+
+	case *AssignExpression:
+		return &AssignExpression{
+			Token:    node.Token,
+			Target:   copyExpression(node.Target),
+			Operator: node.Operator,
+			Value:    copyExpression(node.Value),
+		}
+
+	case *IfExpression:
+		return &IfExpression{
+			Token:       node.Token,
+			Condition:   copyExpression(node.Condition),
+			Consequence: copyBlock(node.Consequence),
+			Alternative: copyBlock(node.Alternative),
+		}
+
+	case *MatchExpression:
+		arms := make([]*MatchArm, len(node.Arms))
+		for i, arm := range node.Arms {
+			arms[i] = &MatchArm{
+				Pattern: copyExpression(arm.Pattern),
+				Body:    copyExpression(arm.Body),
+			}
+		}
+		return &MatchExpression{Token: node.Token, Value: copyExpression(node.Value), Arms: arms}
+
+	case *FunctionLiteral:
+		return &FunctionLiteral{
+			Token:      node.Token,
+			Parameters: copyIdentifiers(node.Parameters),
+			Body:       copyBlock(node.Body),
+			Name:       node.Name,
+		}
+
+	case *MacroLiteral:
+		return &MacroLiteral{
+			Token:      node.Token,
+			Parameters: copyIdentifiers(node.Parameters),
+			Body:       copyBlock(node.Body),
+		}
+
+	case *CallExpression:
+		return &CallExpression{
+			Token:     node.Token,
+			Function:  copyExpression(node.Function),
+			Arguments: copyExpressions(node.Arguments),
+		}
+
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: node.Token, Elements: copyExpressions(node.Elements)}
+
+	case *IndexExpression:
+		return &IndexExpression{
+			Token: node.Token,
+			Left:  copyExpression(node.Left),
+			Index: copyExpression(node.Index),
+		}
+
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression, len(node.Pairs))
+		for key, val := range node.Pairs {
+			pairs[copyExpression(key)] = copyExpression(val)
+		}
+		return &HashLiteral{Token: node.Token, Pairs: pairs}
+	}
+
+	return node
+}
+
+// copyExpression copies e unless it is missing, as it is in trees
+// the parser gave up on
+func copyExpression(e Expression) Expression {
+	if e == nil {
+		return nil
+	}
+	copied, _ := Copy(e).(Expression)
+	return copied
+}
+
+func copyExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	copied := make([]Expression, len(exps))
+	for i, e := range exps {
+		copied[i] = copyExpression(e)
+	}
+	return copied
+}
+
+func copyStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+	copied := make([]Statement, len(stmts))
+	for i, s := range stmts {
+		copied[i], _ = Copy(s).(Statement)
+	}
+	return copied
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return &BlockStatement{Token: block.Token, Statements: copyStatements(block.Statements)}
+}
+
+func copyIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	copied := *ident
+	return &copied
+}
+
+func copyIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+	copied := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		copied[i] = copyIdentifier(ident)
+	}
+	return copied
+}
@@ -0,0 +1,76 @@
+// Package ast ast/copy_test.go
+package ast
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	one := func() Expression {
+		return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1}
+	}
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+	block := func(e Expression) *BlockStatement {
+		return &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: e}}}
+	}
+
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: ident("f"),
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{ident("x")},
+					Body:       block(&InfixExpression{Left: ident("x"), Operator: "+", Right: one()}),
+					Name:       "f",
+				},
+			},
+			&WhileStatement{Condition: &Boolean{Value: true}, Body: &BlockStatement{
+				Statements: []Statement{&BreakStatement{}, &ContinueStatement{}},
+			}},
+			&ForStatement{Variable: ident("x"), Iterable: &ArrayLiteral{Elements: []Expression{one()}}, Body: block(one())},
+			&ReturnStatement{ReturnValue: &PrefixExpression{Operator: "-", Right: one()}},
+			&ExpressionStatement{Expression: &IfExpression{
+				Condition:   one(),
+				Consequence: block(&CallExpression{Function: ident("f"), Arguments: []Expression{one()}}),
+			}},
+			&ExpressionStatement{Expression: &AssignExpression{
+				Target:   &IndexExpression{Left: ident("a"), Index: one()},
+				Operator: "+=",
+				Value:    &HashLiteral{Pairs: map[Expression]Expression{one(): &StringLiteral{Value: "one"}}},
+			}},
+			&ExpressionStatement{Expression: &MatchExpression{
+				Value: ident("x"),
+				Arms:  []*MatchArm{{Pattern: one(), Body: &FloatLiteral{Value: 1.5}}},
+			}},
+			&LetStatement{Name: ident("m"), Value: &MacroLiteral{
+				Parameters: []*Identifier{ident("a")},
+				Body:       block(one()),
+			}},
+		},
+	}
+	original := program.String()
+
+	copied := Copy(program)
+	if copied.String() != original {
+		t.Fatalf("copy not equal. got=%s, want=%s", copied, original)
+	}
+
+	// modifying the copy leaves the original alone
+	Modify(copied, func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok {
+			integer.Value = 2
+			integer.Token.Literal = "2"
+		}
+		if ident, ok := node.(*Identifier); ok {
+			ident.Value = "y"
+		}
+		return node
+	})
+	if program.String() != original {
+		t.Errorf("original modified. got=%s, want=%s", program.String(), original)
+	}
+	if copied.String() == original {
+		t.Errorf("copy not modified. got=%s", copied.String())
+	}
+}
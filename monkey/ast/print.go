@@ -0,0 +1,50 @@
+// Package ast ast/print.go
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Fprint writes the tree below node to w, one line per node with
+// its type and position, indented by depth
+func Fprint(w io.Writer, node Node) {
+	Walk(node, &printer{out: w})
+}
+
+type printer struct {
+	out   io.Writer
+	depth int
+}
+
+// Visit interface method
+func (p *printer) Visit(node Node) Visitor {
+	if node == nil {
+		p.depth--
+		return nil
+	}
+
+	name := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	line := fmt.Sprintf("%s%s %s", strings.Repeat("  ", p.depth), name, node.Pos())
+	switch node := node.(type) {
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *Boolean:
+		line += " " + node.String()
+	case *StringLiteral:
+		line += fmt.Sprintf(" %q", node.Value)
+	case *PrefixExpression:
+		line += " " + node.Operator
+	case *InfixExpression:
+		line += " " + node.Operator
+	case *AssignExpression:
+		line += " " + node.Operator
+	case *FunctionLiteral:
+		if node.Name != "" {
+			line += " " + node.Name
+		}
+	}
+	fmt.Fprintln(p.out, line)
+
+	p.depth++
+	return p
+}
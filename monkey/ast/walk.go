@@ -0,0 +1,148 @@
+// Package ast ast/walk.go
+package ast
+
+import "sort"
+
+// Visitor interface
+// Visit is called for every node reached by Walk. If it returns
+// a non-nil visitor w, Walk visits the children of the node with
+// w and then calls w.Visit(nil)
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree below node depth-first, in source order
+func Walk(node Node, v Visitor) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	// identifiers, literals, break and continue have no children
+	switch node := node.(type) {
+
+	case *Program:
+		for _, s := range node.Statements {
+			Walk(s, v)
+		}
+
+	case *LetStatement:
+		Walk(node.Name, v)
+		walkExpression(node.Value, v)
+
+	case *ReturnStatement:
+		walkExpression(node.ReturnValue, v)
+
+	case *ExpressionStatement:
+		walkExpression(node.Expression, v)
+
+	case *BlockStatement:
+		for _, s := range node.Statements {
+			Walk(s, v)
+		}
+
+	case *WhileStatement:
+		walkExpression(node.Condition, v)
+		Walk(node.Body, v)
+
+	case *ForStatement:
+		Walk(node.Variable, v)
+		walkExpression(node.Iterable, v)
+		Walk(node.Body, v)
+
+	case *PrefixExpression:
+		walkExpression(node.Right, v)
+
+	case *InfixExpression:
+		walkExpression(node.Left, v)
+		walkExpression(node.Right, v)
+
+	case *AssignExpression:
+		walkExpression(node.Target, v)
+		walkExpression(node.Value, v)
+
+	case *IfExpression:
+		walkExpression(node.Condition, v)
+		Walk(node.Consequence, v)
+		if node.Alternative != nil {
+			Walk(node.Alternative, v)
+		}
+
+	case *MatchExpression:
+		walkExpression(node.Value, v)
+		for _, arm := range node.Arms {
+			walkExpression(arm.Pattern, v)
+			walkExpression(arm.Body, v)
+		}
+
+	case *FunctionLiteral:
+		for _, p := range node.Parameters {
+			Walk(p, v)
+		}
+		Walk(node.Body, v)
+
+	case *MacroLiteral:
+		for _, p := range node.Parameters {
+			Walk(p, v)
+		}
+		Walk(node.Body, v)
+
+	case *CallExpression:
+		walkExpression(node.Function, v)
+		for _, a := range node.Arguments {
+			walkExpression(a, v)
+		}
+
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			walkExpression(el, v)
+		}
+
+	case *IndexExpression:
+		walkExpression(node.Left, v)
+		walkExpression(node.Index, v)
+
+	case *HashLiteral:
+		for _, key := range SortedKeys(node) {
+			walkExpression(key, v)
+			walkExpression(node.Pairs[key], v)
+		}
+	}
+
+	v.Visit(nil)
+}
+
+// walkExpression walks e unless it is missing, as it is in trees
+// the parser gave up on
+func walkExpression(e Expression, v Visitor) {
+	if e != nil {
+		Walk(e, v)
+	}
+}
+
+// SortedKeys returns the keys of a hash literal in the order of
+// their source text, which is the order the compiler emits them in
+func SortedKeys(hl *HashLiteral) []Expression {
+	keys := make([]Expression, 0, len(hl.Pairs))
+	for k := range hl.Pairs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect walks the tree below node, calling f for every node and
+// skipping the children of nodes for which f returns false
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
@@ -0,0 +1,128 @@
+// Package ast ast/walk_test.go
+package ast
+
+import (
+	"fmt"
+	"monkey/token"
+	"reflect"
+	"testing"
+)
+
+type recorder struct {
+	visited []string
+}
+
+func (r *recorder) Visit(node Node) Visitor {
+	if node == nil {
+		r.visited = append(r.visited, "end")
+		return nil
+	}
+	r.visited = append(r.visited, fmt.Sprintf("%T", node))
+	return r
+}
+
+func TestWalk(t *testing.T) {
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+	integer := func(value int64) *IntegerLiteral { return &IntegerLiteral{Value: value} }
+	block := func(e Expression) *BlockStatement {
+		return &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: e}}}
+	}
+
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: ident("f"),
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{ident("x")},
+					Body:       block(&InfixExpression{Left: ident("x"), Operator: "+", Right: integer(1)}),
+				},
+			},
+			&WhileStatement{Condition: &Boolean{Value: true}, Body: &BlockStatement{
+				Statements: []Statement{&BreakStatement{}},
+			}},
+			&ExpressionStatement{Expression: &HashLiteral{Pairs: map[Expression]Expression{
+				&StringLiteral{Token: token.Token{Literal: "b"}, Value: "b"}: integer(2),
+				&StringLiteral{Token: token.Token{Literal: "a"}, Value: "a"}: &FloatLiteral{Value: 1.5},
+			}}},
+			&ExpressionStatement{Expression: &MatchExpression{
+				Value: ident("v"),
+				Arms:  []*MatchArm{{Pattern: &ArrayLiteral{Elements: []Expression{ident("y")}}, Body: ident("y")}},
+			}},
+		},
+	}
+
+	r := &recorder{}
+	Walk(program, r)
+
+	expected := []string{
+		"*ast.Program",
+		"*ast.LetStatement",
+		"*ast.Identifier", "end",
+		"*ast.FunctionLiteral",
+		"*ast.Identifier", "end",
+		"*ast.BlockStatement",
+		"*ast.ExpressionStatement",
+		"*ast.InfixExpression",
+		"*ast.Identifier", "end",
+		"*ast.IntegerLiteral", "end",
+		"end", "end", "end", "end", "end",
+		"*ast.WhileStatement",
+		"*ast.Boolean", "end",
+		"*ast.BlockStatement",
+		"*ast.BreakStatement", "end",
+		"end", "end",
+		"*ast.ExpressionStatement",
+		"*ast.HashLiteral",
+		"*ast.StringLiteral", "end",
+		"*ast.FloatLiteral", "end",
+		"*ast.StringLiteral", "end",
+		"*ast.IntegerLiteral", "end",
+		"end", "end",
+		"*ast.ExpressionStatement",
+		"*ast.MatchExpression",
+		"*ast.Identifier", "end",
+		"*ast.ArrayLiteral",
+		"*ast.Identifier", "end",
+		"end",
+		"*ast.Identifier", "end",
+		"end", "end",
+		"end",
+	}
+
+	if !reflect.DeepEqual(r.visited, expected) {
+		t.Errorf("wrong walk order.\nwant=%v\n got=%v", expected, r.visited)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{Expression: &CallExpression{
+				Function: &Identifier{Value: "f"},
+				Arguments: []Expression{
+					&Identifier{Value: "a"},
+					&FunctionLiteral{
+						Parameters: []*Identifier{{Value: "b"}},
+						Body:       &BlockStatement{},
+					},
+					&AssignExpression{Target: &Identifier{Value: "c"}, Operator: "=", Value: &Identifier{Value: "d"}},
+				},
+			}},
+		},
+	}
+
+	names := []string{}
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		// do not look inside functions
+		_, isFunction := node.(*FunctionLiteral)
+		return !isFunction
+	})
+
+	expected := []string{"f", "a", "c", "d"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("wrong identifiers. want=%v, got=%v", expected, names)
+	}
+}
@@ -74,6 +74,50 @@ const (
 	OpGetFree
 	// OpCurrentClosure opcode
 	OpCurrentClosure
+	// OpLessThan opcode
+	OpLessThan
+	// OpLessThanOrEqual opcode
+	OpLessThanOrEqual
+	// OpGreaterThanOrEqual opcode
+	OpGreaterThanOrEqual
+	// OpMod opcode
+	OpMod
+	// OpJumpNotTruthyOrPop jumps if the top of the stack is not
+	// truthy, leaving it there, and otherwise pops it
+	OpJumpNotTruthyOrPop
+	// OpJumpTruthyOrPop jumps if the top of the stack is
+	// truthy, leaving it there, and otherwise pops it
+	OpJumpTruthyOrPop
+	// OpIterator replaces the collection on top of the
+	// stack with an iterator over it
+	OpIterator
+	// OpIteratorNext pops an iterator and pushes its next
+	// element, or jumps when it is exhausted
+	OpIteratorNext
+	// OpSetFree opcode
+	OpSetFree
+	// OpGetLocalCell pushes the cell holding a local variable,
+	// so a closure can capture it by reference
+	OpGetLocalCell
+	// OpGetFreeCell pushes the cell holding a free variable,
+	// so a nested closure can capture it by reference
+	OpGetFreeCell
+	// OpSetIndex pops a value, an index and a collection and stores
+	// the value at the index; a non-zero operand is the binary
+	// opcode of a compound assignment such as +=
+	OpSetIndex
+	// OpMatchValue pops a literal pattern and a value and pushes
+	// whether they are of the same type and equal
+	OpMatchValue
+	// OpMatchArray pops a value and pushes whether it is an
+	// array with exactly the operand's number of elements
+	OpMatchArray
+	// OpMatchHash pops the operand's number of keys and a value
+	// and pushes whether the value is a hash holding every key
+	OpMatchHash
+	// OpTailCall calls a function as OpCall does, in tail position,
+	// so the VM can reuse the frame of the caller for a closure
+	OpTailCall
 )
 
 // Definition struct
@@ -113,6 +157,23 @@ var definitions = map[Opcode]*Definition{
 	OpClosure:        {"OpClosure", []int{2, 1}},
 	OpGetFree:        {"OpGetFree", []int{1}},
 	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+
+	OpLessThan:           {"OpLessThan", []int{}},
+	OpLessThanOrEqual:    {"OpLessThanOrEqual", []int{}},
+	OpGreaterThanOrEqual: {"OpGreaterThanOrEqual", []int{}},
+	OpMod:                {"OpMod", []int{}},
+	OpJumpNotTruthyOrPop: {"OpJumpNotTruthyOrPop", []int{2}},
+	OpJumpTruthyOrPop:    {"OpJumpTruthyOrPop", []int{2}},
+	OpIterator:           {"OpIterator", []int{}},
+	OpIteratorNext:       {"OpIteratorNext", []int{2}},
+	OpSetFree:            {"OpSetFree", []int{1}},
+	OpGetLocalCell:       {"OpGetLocalCell", []int{1}},
+	OpGetFreeCell:        {"OpGetFreeCell", []int{1}},
+	OpSetIndex:           {"OpSetIndex", []int{1}},
+	OpMatchValue:         {"OpMatchValue", []int{}},
+	OpMatchArray:         {"OpMatchArray", []int{2}},
+	OpMatchHash:          {"OpMatchHash", []int{2}},
+	OpTailCall:           {"OpTailCall", []int{1}},
 }
 
 // Lookup func
@@ -157,6 +218,7 @@ func (ins Instructions) String() string {
 		def, err := Lookup(ins[i])
 		if err != nil {
 			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
 			continue
 		}
 		operands, read := ReadOperands(def, ins[i+1:])
@@ -1,7 +1,10 @@
 // Package code code/code_test.go
 package code
 
-import "testing"
+import (
+	"monkey/token"
+	"testing"
+)
 
 func TestMake(t *testing.T) {
 	tests := []struct {
@@ -42,7 +45,7 @@ func TestInstructionsString(t *testing.T) {
 		"0001 OpGetLocal 1\n" +
 		"0003 OpConstant 2\n" +
 		"0006 OpConstant 65535\n" +
-		"0006 OpClosure 65535 255\n"
+		"0009 OpClosure 65535 255\n"
 
 	concatted := Instructions{}
 
@@ -56,6 +59,17 @@ func TestInstructionsString(t *testing.T) {
 	}
 }
 
+func TestInstructionsStringUndefinedOpcode(t *testing.T) {
+	concatted := append(Instructions{255}, Make(OpAdd)...)
+	expected := "ERROR: opcode 255 undefined\n" +
+		"0001 OpAdd\n"
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q",
+			expected, concatted.String())
+	}
+}
+
 func TestReadOperands(t *testing.T) {
 
 	tests := []struct {
@@ -89,3 +103,52 @@ func TestReadOperands(t *testing.T) {
 		}
 	}
 }
+
+func TestSourceMapLookup(t *testing.T) {
+	span := func(line, col, width int) token.Span {
+		start := token.Position{Line: line, Column: col}
+		end := token.Position{Line: line, Column: col + width}
+		return token.Span{Start: start, End: end}
+	}
+	sourceMap := SourceMap{
+		File: "main.mk",
+		Entries: []SourceMapEntry{
+			{Offset: 0, Span: span(1, 1, 3)},
+			{Offset: 3, Span: span(1, 5, 1)},
+			{Offset: 7, Span: span(2, 3, 2)},
+		},
+	}
+
+	tests := []struct {
+		offset   int
+		expected string
+	}{
+		{0, "main.mk:1:1"},
+		{2, "main.mk:1:1"},
+		{3, "main.mk:1:5"},
+		{6, "main.mk:1:5"},
+		{7, "main.mk:2:3"},
+		{100, "main.mk:2:3"},
+	}
+
+	for _, tt := range tests {
+		location, ok := sourceMap.Location(tt.offset)
+		if !ok {
+			t.Fatalf("no location found for offset %d", tt.offset)
+		}
+		if location.String() != tt.expected {
+			t.Errorf("wrong location for offset %d. want=%s, got=%s",
+				tt.offset, tt.expected, location)
+		}
+	}
+
+	got, _ := sourceMap.Lookup(7)
+	if got != span(2, 3, 2) {
+		t.Errorf("wrong span for offset 7. want=%s, got=%s",
+			span(2, 3, 2), got)
+	}
+
+	if _, ok := (SourceMap{}).Lookup(0); ok {
+		t.Errorf("empty source map should not find a span")
+	}
+}
@@ -0,0 +1,54 @@
+// Package code code/sourcemap.go
+package code
+
+import (
+	"monkey/token"
+	"sort"
+)
+
+// SourceMapEntry maps the instruction starting at Offset
+// to the source span of the node it was compiled from
+type SourceMapEntry struct {
+	Offset int
+	Span   token.Span
+}
+
+// SourceMap maps instruction offsets of one instruction
+// sequence back to the source it was compiled from.
+// Entries are sorted by Offset and an entry covers every
+// instruction up to the next entry.
+type SourceMap struct {
+	File    string
+	Entries []SourceMapEntry
+}
+
+// Lookup returns the source span of the instruction at offset
+func (sm SourceMap) Lookup(offset int) (token.Span, bool) {
+	i := sort.Search(len(sm.Entries), func(i int) bool {
+		return sm.Entries[i].Offset > offset
+	})
+	if i == 0 {
+		return token.Span{}, false
+	}
+	return sm.Entries[i-1].Span, true
+}
+
+// Location returns the source location of the instruction at offset
+func (sm SourceMap) Location(offset int) (Location, bool) {
+	span, ok := sm.Lookup(offset)
+	return Location{File: sm.File, Span: span}, ok
+}
+
+// Location is a span of source text in a named file
+type Location struct {
+	File string
+	Span token.Span
+}
+
+// String returns the location as file:line:col
+func (l Location) String() string {
+	if l.File == "" {
+		return l.Span.Start.String()
+	}
+	return l.File + ":" + l.Span.Start.String()
+}
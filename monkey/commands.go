@@ -0,0 +1,288 @@
+// Package main commands.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/repl"
+	"monkey/vm"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// runScript implements monkey run
+func runScript(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("run", "[--engine=vm|eval] [--optimize] [--max-instructions=n] "+
+		"[--max-memory=bytes] [--max-depth=n] [--timeout=duration] <file>", stderr)
+	engine := fs.String("engine", "vm", "use 'vm' or 'eval'")
+	optimize := fs.Bool("optimize", false, "optimize the bytecode of the vm engine")
+	var limits object.Limits
+	fs.Int64Var(&limits.MaxInstructions, "max-instructions", 0,
+		"stop the script after `n` instructions (0 for no limit)")
+	fs.Int64Var(&limits.MaxMemory, "max-memory", 0,
+		"stop the script after it allocates `bytes` (0 for no limit)")
+	fs.IntVar(&limits.MaxDepth, "max-depth", object.DefaultMaxDepth,
+		"fail after `n` nested function calls")
+	fs.DurationVar(&limits.Timeout, "timeout", 0,
+		"stop the script after `duration` (0 for no limit)")
+	filename, ok := parseFileArgs(fs, args, stderr)
+	if !ok {
+		return exitUsage
+	}
+
+	ctx := &object.Context{Stdout: stdout, Stderr: stderr, Stdin: stdin}
+	switch *engine {
+	case "vm":
+		bytecode, ok := loadBytecode(filename, *optimize, stderr)
+		if !ok {
+			return exitError
+		}
+		machine := vm.New(bytecode)
+		machine.SetContext(ctx)
+		machine.SetLimits(limits)
+		err := machine.Run()
+		if err != nil {
+			switch err := err.(type) {
+			case *object.RuntimeError:
+				fmt.Fprintln(stderr, err.Traceback())
+				return exitError
+			case *object.AbortError:
+				fmt.Fprintln(stderr, err.Traceback())
+				return exitError
+			}
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+
+	case "eval":
+		if isBytecodeFile(filename) {
+			fmt.Fprintf(stderr, "monkey run: %s: the eval engine cannot run "+
+				"bytecode, use --engine=vm\n", filename)
+			return exitUsage
+		}
+		program, ok := loadProgram(filename, stderr)
+		if !ok {
+			return exitError
+		}
+		env := object.NewEnvironment()
+		env.SetContext(ctx)
+		result := evaluator.EvalContext(context.Background(), program, env, limits)
+		if errObj, ok := result.(*object.Error); ok {
+			for i := range errObj.Stack {
+				errObj.Stack[i].Location.File = filename
+			}
+			fmt.Fprintln(stderr, errObj.Traceback())
+			return exitError
+		}
+
+	default:
+		fmt.Fprintf(stderr, "monkey run: unknown engine %q, "+
+			"use 'vm' or 'eval'\n", *engine)
+		return exitUsage
+	}
+	return exitOK
+}
+
+// runBuild implements monkey build
+func runBuild(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("build", "[--optimize] [-o <output>] <file>", stderr)
+	output := fs.String("o", "", "write the bytecode to `file` "+
+		"(default: the input with a "+compiler.FileExtension+" extension)")
+	optimize := fs.Bool("optimize", false, "optimize the bytecode")
+	filename, ok := parseFileArgs(fs, args, stderr)
+	if !ok {
+		return exitUsage
+	}
+	if isBytecodeFile(filename) {
+		fmt.Fprintf(stderr, "monkey build: %s is already bytecode\n", filename)
+		return exitUsage
+	}
+
+	bytecode, ok := loadBytecode(filename, *optimize, stderr)
+	if !ok {
+		return exitError
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(filename, filepath.Ext(filename)) +
+			compiler.FileExtension
+	}
+	err := compiler.WriteBytecodeFile(*output, bytecode)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey build: %s\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// runDis implements monkey dis
+func runDis(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("dis", "[--optimize] <file>", stderr)
+	optimize := fs.Bool("optimize", false, "optimize the bytecode of a script")
+	filename, ok := parseFileArgs(fs, args, stderr)
+	if !ok {
+		return exitUsage
+	}
+
+	bytecode, ok := loadBytecode(filename, *optimize, stderr)
+	if !ok {
+		return exitError
+	}
+	compiler.Disassemble(stdout, bytecode)
+	return exitOK
+}
+
+// runAst implements monkey ast
+func runAst(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("ast", "<file>", stderr)
+	filename, ok := parseFileArgs(fs, args, stderr)
+	if !ok {
+		return exitUsage
+	}
+	if isBytecodeFile(filename) {
+		fmt.Fprintf(stderr, "monkey ast: %s is bytecode, not a script\n", filename)
+		return exitUsage
+	}
+
+	program, ok := parseFile(filename, stderr)
+	if !ok {
+		return exitError
+	}
+	ast.Fprint(stdout, program)
+	return exitOK
+}
+
+// runRepl implements monkey repl
+func runRepl(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("repl", "", stderr)
+	err := fs.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(stderr, "monkey repl: unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+
+	name := "there"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	fmt.Fprintf(stdout, "Hello %s! This is the Monkey "+
+		"programming language!\n", name)
+	fmt.Fprintf(stdout, "Feel free to type in commands\n")
+
+	repl.Start(stdin, stdout)
+	return exitOK
+}
+
+func newFlagSet(command, arguments string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("monkey "+command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: monkey %s %s\n", command, arguments)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFileArgs parses the flags, which may come before or after
+// the file name, and returns the one file name
+func parseFileArgs(fs *flag.FlagSet, args []string, stderr io.Writer) (string, bool) {
+	var files []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return "", false
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(files) != 1 {
+		fmt.Fprintf(stderr, "%s: expected one file, got %d\n", fs.Name(), len(files))
+		fs.Usage()
+		return "", false
+	}
+	return files[0], true
+}
+
+func isBytecodeFile(filename string) bool {
+	return filepath.Ext(filename) == compiler.FileExtension
+}
+
+// parseFile reads and parses a script, printing any errors
+func parseFile(filename string, stderr io.Writer) (*ast.Program, bool) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, false
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(stderr, "%s:%s\n", filename, msg)
+		}
+		return nil, false
+	}
+	return program, true
+}
+
+// loadProgram parses a script and expands its macros
+func loadProgram(filename string, stderr io.Writer) (*ast.Program, bool) {
+	program, ok := parseFile(filename, stderr)
+	if !ok {
+		return nil, false
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s:%s\n", filename, err)
+		return nil, false
+	}
+	return expanded.(*ast.Program), true
+}
+
+// loadBytecode reads a bytecode file, or compiles a script,
+// optimizing it if optimize is set
+func loadBytecode(filename string, optimize bool, stderr io.Writer) (*compiler.Bytecode, bool) {
+	if isBytecodeFile(filename) {
+		bytecode, err := compiler.ReadBytecodeFile(filename)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return nil, false
+		}
+		return bytecode, true
+	}
+
+	program, ok := loadProgram(filename, stderr)
+	if !ok {
+		return nil, false
+	}
+	comp := compiler.New()
+	comp.SetFile(filename)
+	comp.SetOptimize(optimize)
+	err := comp.Compile(program)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, false
+	}
+	return comp.Bytecode(), true
+}
@@ -6,7 +6,8 @@ import (
 	"monkey/ast"
 	"monkey/code"
 	"monkey/object"
-	"sort"
+	"monkey/token"
+	"strings"
 )
 
 // Compiler struct
@@ -17,14 +18,41 @@ type Compiler struct {
 
 	scopes     []CompilationScope
 	scopeIndex int
+
+	// name of the source file being compiled, used in source maps
+	file string
+	// source span of the node being compiled
+	span token.Span
+	// whether to optimize, see SetOptimize
+	optimize bool
+	// whether the tree being compiled is folded already
+	folded bool
 }
 
 // CompilationScope struct
 type CompilationScope struct {
 	instructions code.Instructions
+	sourceMap    []code.SourceMapEntry
 
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+
+	// loops enclosing the code being compiled, innermost last
+	loops []loopScope
+	// number of values that the expressions enclosing the code
+	// being compiled have pushed and not consumed yet
+	operands int
+}
+
+// loopScope tracks the jump targets of a loop
+type loopScope struct {
+	continuePos int
+	// operands of the scope when the loop was entered, the ones
+	// above them are popped when jumping out of an expression
+	operands int
+	// positions of OpJump instructions to patch with
+	// the position after the loop
+	breakJumps []int
 }
 
 // New func
@@ -35,9 +63,7 @@ func New() *Compiler {
 		previousInstruction: EmittedInstruction{},
 	}
 	symbolTable := NewSymbolTable()
-	for i, v := range object.Builtins {
-		symbolTable.DefineBuiltin(i, v.Name)
-	}
+	symbolTable.DefineBuiltins(object.StandardRegistry)
 	return &Compiler{
 		constants:   []object.Object{},
 		symbolTable: symbolTable,
@@ -54,8 +80,26 @@ func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
 	return compile
 }
 
+// SetFile sets the file name recorded in source maps and errors
+func (c *Compiler) SetFile(file string) {
+	c.file = file
+}
+
 // Compile func
 func (c *Compiler) Compile(node ast.Node) error {
+	if c.optimize && !c.folded {
+		// fold the whole tree once, before compiling it
+		c.folded = true
+		defer func() { c.folded = false }()
+		node = foldTree(node)
+	}
+
+	previousSpan := c.span
+	if pos := node.Pos(); pos.IsValid() {
+		c.span = nodeSpan(node)
+	}
+	defer func() { c.span = previousSpan }()
+
 	switch node := node.(type) {
 	case *ast.Program:
 		for _, s := range node.Statements {
@@ -73,18 +117,24 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpPop)
 
 	case *ast.InfixExpression:
-		// special treatment for '<' to swap order of operands
-		// This is to show how to reuse an existing operator.
-		if node.Operator == "<" {
-			err := c.Compile(node.Right)
+		// '&&' and '||' only evaluate the right operand when
+		// the left one does not decide the result
+		if node.Operator == "&&" || node.Operator == "||" {
+			err := c.Compile(node.Left)
 			if err != nil {
 				return err
 			}
-			err = c.Compile(node.Left)
+			op := code.OpJumpNotTruthyOrPop
+			if node.Operator == "||" {
+				op = code.OpJumpTruthyOrPop
+			}
+			// Emit the jump with a bogus value
+			jumpPos := c.emit(op, 9999)
+			err = c.Compile(node.Right)
 			if err != nil {
 				return err
 			}
-			c.emit(code.OpGreaterThan)
+			c.changeOperand(jumpPos, len(c.currentInstructions()))
 			return nil
 		}
 		// otherwise order is left then right
@@ -92,7 +142,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err != nil {
 			return err
 		}
-		err = c.Compile(node.Right)
+		err = c.compileOperand(node.Right, 1)
 		if err != nil {
 			return err
 		}
@@ -106,20 +156,32 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpMul)
 		case "/":
 			c.emit(code.OpDiv)
+		case "%":
+			c.emit(code.OpMod)
 		case ">":
 			c.emit(code.OpGreaterThan)
+		case "<":
+			c.emit(code.OpLessThan)
+		case ">=":
+			c.emit(code.OpGreaterThanOrEqual)
+		case "<=":
+			c.emit(code.OpLessThanOrEqual)
 		case "==":
 			c.emit(code.OpEqual)
 		case "!=":
 			c.emit(code.OpNotEqual)
 		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
+			return c.errorf(node, "unknown operator %s", node.Operator)
 		}
 
 	case *ast.IntegerLiteral:
 		integer := &object.Integer{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(integer))
 
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
 	case *ast.Boolean:
 		if node.Value {
 			c.emit(code.OpTrue)
@@ -138,10 +200,16 @@ func (c *Compiler) Compile(node ast.Node) error {
 		case "-":
 			c.emit(code.OpMinus)
 		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
+			return c.errorf(node, "unknown operator %s", node.Operator)
 		}
 
 	case *ast.IfExpression:
+		if c.optimize {
+			if block, ok := constantBranch(node); ok {
+				return c.compileBranch(block)
+			}
+		}
+
 		err := c.Compile(node.Condition)
 		if err != nil {
 			return err
@@ -150,31 +218,20 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// Emit an OpJumpNotTruthy with bogus value
 		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
 
-		err = c.Compile(node.Consequence)
+		err = c.compileBranch(node.Consequence)
 		if err != nil {
 			return err
 		}
 
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
-		}
-
 		// Emit an OpJump with a bogus value
 		jumpPos := c.emit(code.OpJump, 9999)
 
 		afterConsequencePos := len(c.currentInstructions())
 		c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
 
-		if node.Alternative == nil {
-			c.emit(code.OpNull)
-		} else {
-			err = c.Compile(node.Alternative)
-			if err != nil {
-				return err
-			}
-			if c.lastInstructionIs(code.OpPop) {
-				c.removeLastPop()
-			}
+		err = c.compileBranch(node.Alternative)
+		if err != nil {
+			return err
 		}
 
 		afterAlternativePos := len(c.currentInstructions())
@@ -189,21 +246,92 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.LetStatement:
-		symbol := c.symbolTable.Define(node.Name.Value)
+		// rebinding a name in the same scope reuses its slot, so a loop
+		// body can update a variable its condition reads
+		symbol, ok := c.symbolTable.ResolveOwn(node.Name.Value)
+		if !ok {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
 		}
-		if symbol.Scope == GlobalScope {
-			c.emit(code.OpSetGlobal, symbol.Index)
-		} else {
-			c.emit(code.OpSetLocal, symbol.Index)
+		c.storeSymbol(symbol)
+
+	case *ast.WhileStatement:
+		loopStart := len(c.currentInstructions())
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+
+		// Emit an OpJumpNotTruthy with bogus value
+		exitJumpPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		c.enterLoop(loopStart)
+		err = c.Compile(node.Body)
+		if err != nil {
+			return err
 		}
+		c.emit(code.OpJump, loopStart)
+
+		afterLoopPos := len(c.currentInstructions())
+		c.changeOperand(exitJumpPos, afterLoopPos)
+		c.leaveLoop(afterLoopPos)
+
+	case *ast.ForStatement:
+		err := c.Compile(node.Iterable)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpIterator)
+		// the iterator is kept in a slot no identifier can name
+		iterator := c.symbolTable.Define("$iterator")
+		c.storeSymbol(iterator)
+
+		loopStart := len(c.currentInstructions())
+		c.loadSymbol(iterator)
+
+		// Emit an OpIteratorNext with bogus value
+		exitJumpPos := c.emit(code.OpIteratorNext, 9999)
+		variable := c.symbolTable.Define(node.Variable.Value)
+		c.storeSymbol(variable)
+
+		c.enterLoop(loopStart)
+		err = c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpJump, loopStart)
+
+		afterLoopPos := len(c.currentInstructions())
+		c.changeOperand(exitJumpPos, afterLoopPos)
+		c.leaveLoop(afterLoopPos)
+
+	case *ast.BreakStatement:
+		loops := c.scopes[c.scopeIndex].loops
+		if len(loops) == 0 {
+			return c.errorf(node, "break outside loop")
+		}
+		loop := &loops[len(loops)-1]
+		c.popOperands(loop)
+		// Emit an OpJump with a bogus value
+		jumpPos := c.emit(code.OpJump, 9999)
+		loop.breakJumps = append(loop.breakJumps, jumpPos)
+
+	case *ast.ContinueStatement:
+		loops := c.scopes[c.scopeIndex].loops
+		if len(loops) == 0 {
+			return c.errorf(node, "continue outside loop")
+		}
+		loop := &loops[len(loops)-1]
+		c.popOperands(loop)
+		c.emit(code.OpJump, loop.continuePos)
 
 	case *ast.Identifier:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			return fmt.Errorf("undefined variable %s", node.Value)
+			return c.errorf(node, "undefined variable %s", node.Value)
 		}
 		c.loadSymbol(symbol)
 
@@ -212,8 +340,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpConstant, c.addConstant(str))
 
 	case *ast.ArrayLiteral:
-		for _, el := range node.Elements {
-			err := c.Compile(el)
+		for i, el := range node.Elements {
+			err := c.compileOperand(el, i)
 			if err != nil {
 				return err
 			}
@@ -222,19 +350,12 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpArray, len(node.Elements))
 
 	case *ast.HashLiteral:
-		keys := []ast.Expression{}
-		for k := range node.Pairs {
-			keys = append(keys, k)
-		}
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
-		for _, k := range keys {
-			err := c.Compile(k)
+		for i, k := range ast.SortedKeys(node) {
+			err := c.compileOperand(k, 2*i)
 			if err != nil {
 				return err
 			}
-			err = c.Compile(node.Pairs[k])
+			err = c.compileOperand(node.Pairs[k], 2*i+1)
 			if err != nil {
 				return err
 			}
@@ -246,12 +367,24 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err != nil {
 			return err
 		}
-		err = c.Compile(node.Index)
+		err = c.compileOperand(node.Index, 1)
 		if err != nil {
 			return err
 		}
 		c.emit(code.OpIndex)
 
+	case *ast.AssignExpression:
+		err := c.compileAssignExpression(node)
+		if err != nil {
+			return err
+		}
+
+	case *ast.MatchExpression:
+		err := c.compileMatchExpression(node)
+		if err != nil {
+			return err
+		}
+
 	case *ast.FunctionLiteral:
 
 		c.enterScope()
@@ -273,20 +406,24 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if !c.lastInstructionIs(code.OpReturnValue) {
 			c.emit(code.OpReturn)
 		}
+		c.markTailCalls()
 
 		freeSymbols := c.symbolTable.FreeSymbols
 
 		numLocals := c.symbolTable.numDefinitions
+		sourceMap := c.sourceMap()
 		instructions := c.leaveScope()
 
 		for _, s := range freeSymbols {
-			c.loadSymbol(s)
+			c.loadCell(s)
 		}
 
 		compiledFn := &object.CompiledFunction{
+			Name:          node.Name,
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			SourceMap:     sourceMap,
 		}
 		fnIndex := c.addConstant(compiledFn)
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
@@ -298,15 +435,23 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 		c.emit(code.OpReturnValue)
 
+	case *ast.MacroLiteral:
+		return c.errorf(node, "macro literal outside top-level let")
+
 	case *ast.CallExpression:
+		if fn, ok := node.Function.(*ast.Identifier); ok && fn.Value == "quote" {
+			if _, ok := c.symbolTable.Resolve(fn.Value); !ok {
+				return c.errorf(node, "quote is only supported by the evaluator and in macros")
+			}
+		}
 
 		err := c.Compile(node.Function)
 		if err != nil {
 			return err
 		}
 
-		for _, a := range node.Arguments {
-			err := c.Compile(a)
+		for i, a := range node.Arguments {
+			err := c.compileOperand(a, 1+i)
 			if err != nil {
 				return err
 			}
@@ -322,6 +467,7 @@ func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		SourceMap:    c.sourceMap(),
 	}
 }
 
@@ -335,6 +481,7 @@ func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 	pos := c.addInstruction(ins)
 
 	c.setLastInstruction(op, pos)
+	c.addSourceMapEntry(pos)
 
 	return pos
 }
@@ -346,6 +493,40 @@ func (c *Compiler) addInstruction(ins []byte) int {
 	return posNewInstruction
 }
 
+func (c *Compiler) addSourceMapEntry(offset int) {
+	if !c.span.Start.IsValid() {
+		return
+	}
+	entries := c.scopes[c.scopeIndex].sourceMap
+	if n := len(entries); n > 0 && entries[n-1].Span == c.span {
+		return
+	}
+	entry := code.SourceMapEntry{Offset: offset, Span: c.span}
+	c.scopes[c.scopeIndex].sourceMap = append(entries, entry)
+}
+
+func (c *Compiler) sourceMap() code.SourceMap {
+	return code.SourceMap{
+		File:    c.file,
+		Entries: c.scopes[c.scopeIndex].sourceMap,
+	}
+}
+
+func (c *Compiler) errorf(node ast.Node, format string, a ...interface{}) error {
+	location := code.Location{File: c.file, Span: nodeSpan(node)}
+	return fmt.Errorf("%s: %s", location, fmt.Sprintf(format, a...))
+}
+
+// nodeSpan returns the span of the token that introduced the node
+func nodeSpan(node ast.Node) token.Span {
+	start := node.Pos()
+	literal := node.TokenLiteral()
+	if _, ok := node.(*ast.StringLiteral); ok {
+		literal = `"` + literal + `"`
+	}
+	return token.Span{Start: start, End: start.Advance(literal)}
+}
+
 func (c *Compiler) setLastInstruction(
 	op code.Opcode,
 	pos int,
@@ -370,6 +551,12 @@ func (c *Compiler) removeLastPop() {
 	new := old[:last.Position]
 	c.scopes[c.scopeIndex].instructions = new
 	c.scopes[c.scopeIndex].lastInstruction = previous
+
+	entries := c.scopes[c.scopeIndex].sourceMap
+	for len(entries) > 0 && entries[len(entries)-1].Offset >= last.Position {
+		entries = entries[:len(entries)-1]
+	}
+	c.scopes[c.scopeIndex].sourceMap = entries
 }
 
 func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
@@ -414,6 +601,316 @@ func (c *Compiler) replaceLastPopWithReturn() {
 	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
 }
 
+// compileBranch compiles a branch of an if expression, leaving its
+// value on the stack. A nil block is a missing else branch.
+func (c *Compiler) compileBranch(block *ast.BlockStatement) error {
+	if block == nil {
+		c.emit(code.OpNull)
+		return nil
+	}
+	err := c.Compile(block)
+	if err != nil {
+		return err
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		// the block does not end in an expression
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+// markTailCalls turns the calls of the current scope whose result
+// the function returns, directly or through jumps, into OpTailCalls
+func (c *Compiler) markTailCalls() {
+	ins := c.currentInstructions()
+	for pos := 0; pos < len(ins); {
+		def, err := code.Lookup(ins[pos])
+		if err != nil {
+			return
+		}
+		_, read := code.ReadOperands(def, ins[pos+1:])
+		next := pos + 1 + read
+		if code.Opcode(ins[pos]) == code.OpCall && returnsAt(ins, next) {
+			ins[pos] = byte(code.OpTailCall)
+		}
+		pos = next
+	}
+}
+
+// returnsAt reports whether the instruction at pos, or the one that
+// the jumps starting there lead to, is OpReturnValue
+func returnsAt(ins code.Instructions, pos int) bool {
+	// a jump chain longer than the instructions is a loop
+	for i := 0; i < len(ins) && pos < len(ins); i++ {
+		switch code.Opcode(ins[pos]) {
+		case code.OpReturnValue:
+			return true
+		case code.OpJump:
+			pos = int(code.ReadUint16(ins[pos+1:]))
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// compileOperand compiles node while n values of the expression it
+// is an operand of wait on the stack
+func (c *Compiler) compileOperand(node ast.Node, n int) error {
+	c.scopes[c.scopeIndex].operands += n
+	err := c.Compile(node)
+	c.scopes[c.scopeIndex].operands -= n
+	return err
+}
+
+// popOperands pops the values that the expressions inside loop have
+// pushed, before a break or continue jumps out of them
+func (c *Compiler) popOperands(loop *loopScope) {
+	for i := loop.operands; i < c.scopes[c.scopeIndex].operands; i++ {
+		c.emit(code.OpPop)
+	}
+}
+
+func (c *Compiler) enterLoop(continuePos int) {
+	scope := &c.scopes[c.scopeIndex]
+	loop := loopScope{continuePos: continuePos, operands: scope.operands}
+	scope.loops = append(scope.loops, loop)
+}
+
+func (c *Compiler) leaveLoop(afterLoopPos int) {
+	loops := c.scopes[c.scopeIndex].loops
+	for _, pos := range loops[len(loops)-1].breakJumps {
+		c.changeOperand(pos, afterLoopPos)
+	}
+	c.scopes[c.scopeIndex].loops = loops[:len(loops)-1]
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	default:
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+// loadCell pushes the cell behind a captured variable instead of
+// its value, so the closure being built shares the variable
+func (c *Compiler) loadCell(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpGetLocalCell, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFreeCell, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+// patternBinding is an identifier in a match pattern and the
+// path of indexes from the matched value to the part it binds
+type patternBinding struct {
+	name string
+	path []ast.Expression
+}
+
+// compileMatchExpression compiles a match into a chain of pattern
+// tests, each jumping to the next arm when it fails
+func (c *Compiler) compileMatchExpression(node *ast.MatchExpression) error {
+	err := c.Compile(node.Value)
+	if err != nil {
+		return err
+	}
+	// the matched value is kept in a slot no identifier can name
+	subject := c.symbolTable.Define("$match")
+	c.storeSymbol(subject)
+
+	endJumps := []int{}
+	for _, arm := range node.Arms {
+		failJumps := []int{}
+		bindings := []patternBinding{}
+		err := c.compilePattern(subject, arm.Pattern, nil, &failJumps, &bindings)
+		if err != nil {
+			return err
+		}
+
+		for _, b := range bindings {
+			c.loadPath(subject, b.path)
+			symbol, ok := c.symbolTable.ResolveOwn(b.name)
+			if !ok {
+				symbol = c.symbolTable.Define(b.name)
+			}
+			c.storeSymbol(symbol)
+		}
+
+		err = c.Compile(arm.Body)
+		if err != nil {
+			return err
+		}
+		// Emit an OpJump with a bogus value
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+
+		nextArmPos := len(c.currentInstructions())
+		for _, pos := range failJumps {
+			c.changeOperand(pos, nextArmPos)
+		}
+	}
+
+	// no arm matched
+	c.emit(code.OpNull)
+
+	afterMatchPos := len(c.currentInstructions())
+	for _, pos := range endJumps {
+		c.changeOperand(pos, afterMatchPos)
+	}
+	return nil
+}
+
+func (c *Compiler) compilePattern(
+	subject Symbol,
+	pattern ast.Expression,
+	path []ast.Expression,
+	failJumps *[]int,
+	bindings *[]patternBinding,
+) error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			*bindings = append(*bindings, patternBinding{pattern.Value, path})
+		}
+		return nil
+
+	case *ast.ArrayLiteral:
+		c.loadPath(subject, path)
+		c.emit(code.OpMatchArray, len(pattern.Elements))
+		*failJumps = append(*failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+
+		for i, el := range pattern.Elements {
+			index := &ast.IntegerLiteral{Value: int64(i)}
+			err := c.compilePattern(subject, el, extendPath(path, index), failJumps, bindings)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *ast.HashLiteral:
+		keys := ast.SortedKeys(pattern)
+
+		c.loadPath(subject, path)
+		for _, k := range keys {
+			err := c.Compile(k)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpMatchHash, len(keys))
+		*failJumps = append(*failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+
+		for _, k := range keys {
+			err := c.compilePattern(subject, pattern.Pairs[k], extendPath(path, k), failJumps, bindings)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		c.loadPath(subject, path)
+		err := c.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpMatchValue)
+		*failJumps = append(*failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+		return nil
+	}
+}
+
+// loadPath pushes the part of the matched value reached by
+// indexing it with each expression in path
+func (c *Compiler) loadPath(subject Symbol, path []ast.Expression) {
+	c.loadSymbol(subject)
+	for _, index := range path {
+		// path indexes are literals, which always compile
+		c.Compile(index)
+		c.emit(code.OpIndex)
+	}
+}
+
+func extendPath(path []ast.Expression, index ast.Expression) []ast.Expression {
+	extended := make([]ast.Expression, len(path), len(path)+1)
+	copy(extended, path)
+	return append(extended, index)
+}
+
+var compoundOperators = map[string]code.Opcode{
+	"+=": code.OpAdd,
+	"-=": code.OpSub,
+	"*=": code.OpMul,
+	"/=": code.OpDiv,
+	"%=": code.OpMod,
+}
+
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	op, compound := compoundOperators[node.Operator]
+	if !compound && node.Operator != "=" {
+		return c.errorf(node, "unknown operator %s", node.Operator)
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok {
+			return c.errorf(node, "undefined variable %s", target.Value)
+		}
+		if scope := c.symbolTable.originScope(symbol); scope != GlobalScope && scope != LocalScope {
+			return c.errorf(node, "cannot assign to %s %s",
+				strings.ToLower(string(scope)), target.Value)
+		}
+		operands := 0
+		if compound {
+			c.loadSymbol(symbol)
+			operands = 1
+		}
+		err := c.compileOperand(node.Value, operands)
+		if err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+		c.storeSymbol(symbol)
+		c.loadSymbol(symbol)
+
+	case *ast.IndexExpression:
+		err := c.Compile(target.Left)
+		if err != nil {
+			return err
+		}
+		err = c.compileOperand(target.Index, 1)
+		if err != nil {
+			return err
+		}
+		err = c.compileOperand(node.Value, 2)
+		if err != nil {
+			return err
+		}
+		if !compound {
+			op = 0
+		}
+		c.emit(code.OpSetIndex, int(op))
+
+	default:
+		return c.errorf(node, "cannot assign to %s", node.Target.String())
+	}
+	return nil
+}
+
 func (c *Compiler) loadSymbol(s Symbol) {
 	switch s.Scope {
 	case GlobalScope:
@@ -433,6 +930,7 @@ func (c *Compiler) loadSymbol(s Symbol) {
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	SourceMap    code.SourceMap
 }
 
 // EmittedInstruction struct
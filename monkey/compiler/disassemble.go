@@ -0,0 +1,45 @@
+// Package compiler compiler/disassemble.go
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"monkey/object"
+)
+
+// Disassemble writes the main instructions, the constants and the
+// instructions of every compiled function in the constant pool
+func Disassemble(out io.Writer, bytecode *Bytecode) {
+	fmt.Fprintf(out, "%s:\n%s", object.MainFunctionName, bytecode.Instructions)
+
+	if len(bytecode.Constants) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\nconstants:\n")
+	for i, constant := range bytecode.Constants {
+		switch constant := constant.(type) {
+		case *object.CompiledFunction:
+			fmt.Fprintf(out, "%04d %s %s\n", i, constant.Type(), functionName(constant))
+		case *object.String:
+			fmt.Fprintf(out, "%04d %s %q\n", i, constant.Type(), constant.Value)
+		default:
+			fmt.Fprintf(out, "%04d %s %s\n", i, constant.Type(), constant.Inspect())
+		}
+	}
+
+	for i, constant := range bytecode.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(out, "\nconstant %d, %s (parameters=%d, locals=%d):\n%s",
+			i, functionName(fn), fn.NumParameters, fn.NumLocals, fn.Instructions)
+	}
+}
+
+func functionName(fn *object.CompiledFunction) string {
+	if fn.Name == "" {
+		return object.AnonymousFunctionName
+	}
+	return fn.Name
+}
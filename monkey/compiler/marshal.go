@@ -0,0 +1,531 @@
+// Package compiler compiler/marshal.go
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"math"
+	"monkey/code"
+	"monkey/object"
+	"monkey/token"
+)
+
+// The .mbc format is
+//
+//	magic    4 bytes  "\x7fMBC"
+//	version  uint16   FormatVersion, big endian
+//	payload  ...      the encoded bytecode
+//	checksum uint32   CRC-32 (IEEE) of the payload, big endian
+//
+// The payload holds the main instructions, their source map and the
+// constants. Numbers in the payload are varints, and strings and
+// byte slices are prefixed with their length.
+
+// FileExtension of compiled Monkey programs
+const FileExtension = ".mbc"
+
+// FormatVersion of the bytecode format. It changes whenever the
+// encoding or the opcodes change.
+const FormatVersion = 2
+
+var magic = []byte("\x7fMBC")
+
+// constant kinds in the payload
+const (
+	integerConstant byte = iota + 1
+	floatConstant
+	stringConstant
+	compiledFunctionConstant
+)
+
+var (
+	// ErrNotBytecode is returned by Unmarshal for data without the magic header
+	ErrNotBytecode = errors.New("not a Monkey bytecode file")
+	// ErrChecksum is returned by Unmarshal for corrupted data
+	ErrChecksum = errors.New("bytecode checksum mismatch")
+	// ErrTruncated is returned by Unmarshal for data that ends too early
+	ErrTruncated = errors.New("truncated bytecode")
+)
+
+// Marshal encodes bytecode in the .mbc format
+func Marshal(bytecode *Bytecode) ([]byte, error) {
+	e := &encoder{}
+	e.writeBytes(bytecode.Instructions)
+	e.writeSourceMap(bytecode.SourceMap)
+	e.writeUint(uint64(len(bytecode.Constants)))
+	for i, constant := range bytecode.Constants {
+		err := e.writeConstant(constant)
+		if err != nil {
+			return nil, fmt.Errorf("constant %d: %s", i, err)
+		}
+	}
+
+	payload := e.buf.Bytes()
+	out := bytes.NewBuffer(make([]byte, 0, len(payload)+10))
+	out.Write(magic)
+	binary.Write(out, binary.BigEndian, uint16(FormatVersion))
+	out.Write(payload)
+	binary.Write(out, binary.BigEndian, crc32.ChecksumIEEE(payload))
+	return out.Bytes(), nil
+}
+
+// Unmarshal decodes bytecode in the .mbc format
+func Unmarshal(data []byte) (*Bytecode, error) {
+	if len(data) < len(magic) || !bytes.Equal(data[:len(magic)], magic) {
+		return nil, ErrNotBytecode
+	}
+	data = data[len(magic):]
+	if len(data) < 2+4 {
+		return nil, ErrTruncated
+	}
+	version := binary.BigEndian.Uint16(data)
+	if version != FormatVersion {
+		return nil, fmt.Errorf("unsupported bytecode version %d, want %d",
+			version, FormatVersion)
+	}
+	payload := data[2 : len(data)-4]
+	checksum := binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, ErrChecksum
+	}
+
+	d := &decoder{data: payload}
+	bytecode := &Bytecode{}
+	bytecode.Instructions = d.readBytes()
+	bytecode.SourceMap = d.readSourceMap()
+	numConstants := d.readLength()
+	for i := 0; i < numConstants && d.err == nil; i++ {
+		bytecode.Constants = append(bytecode.Constants, d.readConstant())
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.data) != 0 {
+		return nil, fmt.Errorf("%d unexpected bytes after bytecode", len(d.data))
+	}
+	err := validate(bytecode)
+	if err != nil {
+		return nil, err
+	}
+	return bytecode, nil
+}
+
+// WriteBytecodeFile marshals bytecode into the named file
+func WriteBytecodeFile(filename string, bytecode *Bytecode) error {
+	data, err := Marshal(bytecode)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// ReadBytecodeFile unmarshals the bytecode in the named file
+func ReadBytecodeFile(filename string) (*Bytecode, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	bytecode, err := Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return bytecode, nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) writeUint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	e.buf.Write(b[:n])
+}
+
+func (e *encoder) writeInt(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	e.buf.Write(b[:n])
+}
+
+func (e *encoder) writeBytes(b []byte) {
+	e.writeUint(uint64(len(b)))
+	e.buf.Write(b)
+}
+
+func (e *encoder) writePosition(p token.Position) {
+	e.writeInt(int64(p.Line))
+	e.writeInt(int64(p.Column))
+	e.writeInt(int64(p.Offset))
+}
+
+func (e *encoder) writeSourceMap(sm code.SourceMap) {
+	e.writeBytes([]byte(sm.File))
+	e.writeUint(uint64(len(sm.Entries)))
+	for _, entry := range sm.Entries {
+		e.writeUint(uint64(entry.Offset))
+		e.writePosition(entry.Span.Start)
+		e.writePosition(entry.Span.End)
+	}
+}
+
+func (e *encoder) writeConstant(constant object.Object) error {
+	switch constant := constant.(type) {
+	case *object.Integer:
+		e.buf.WriteByte(integerConstant)
+		e.writeInt(constant.Value)
+	case *object.Float:
+		e.buf.WriteByte(floatConstant)
+		e.writeUint(math.Float64bits(constant.Value))
+	case *object.String:
+		e.buf.WriteByte(stringConstant)
+		e.writeBytes([]byte(constant.Value))
+	case *object.CompiledFunction:
+		e.buf.WriteByte(compiledFunctionConstant)
+		e.writeBytes([]byte(constant.Name))
+		e.writeUint(uint64(constant.NumLocals))
+		e.writeUint(uint64(constant.NumParameters))
+		e.writeBytes(constant.Instructions)
+		e.writeSourceMap(constant.SourceMap)
+	default:
+		return fmt.Errorf("cannot marshal constant of type %s", constant.Type())
+	}
+	return nil
+}
+
+// decoder reads the payload, remembering the first error so that
+// callers can check it once at the end
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) readUint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = ErrTruncated
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) readInt() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.err = ErrTruncated
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// readLength reads a count that must fit in the remaining data
+func (d *decoder) readLength() int {
+	n := d.readUint()
+	if n > uint64(len(d.data)) {
+		if d.err == nil {
+			d.err = ErrTruncated
+		}
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) readByte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) == 0 {
+		d.err = ErrTruncated
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) readBytes() []byte {
+	n := d.readLength()
+	if d.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	copy(b, d.data)
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) readPosition() token.Position {
+	return token.Position{
+		Line:   int(d.readInt()),
+		Column: int(d.readInt()),
+		Offset: int(d.readInt()),
+	}
+}
+
+func (d *decoder) readSourceMap() code.SourceMap {
+	sm := code.SourceMap{File: string(d.readBytes())}
+	numEntries := d.readLength()
+	for i := 0; i < numEntries && d.err == nil; i++ {
+		entry := code.SourceMapEntry{Offset: int(d.readUint())}
+		entry.Span.Start = d.readPosition()
+		entry.Span.End = d.readPosition()
+		sm.Entries = append(sm.Entries, entry)
+	}
+	return sm
+}
+
+func (d *decoder) readConstant() object.Object {
+	switch kind := d.readByte(); kind {
+	case integerConstant:
+		return &object.Integer{Value: d.readInt()}
+	case floatConstant:
+		return &object.Float{Value: math.Float64frombits(d.readUint())}
+	case stringConstant:
+		return &object.String{Value: string(d.readBytes())}
+	case compiledFunctionConstant:
+		return &object.CompiledFunction{
+			Name:          string(d.readBytes()),
+			NumLocals:     int(d.readUint()),
+			NumParameters: int(d.readUint()),
+			Instructions:  d.readBytes(),
+			SourceMap:     d.readSourceMap(),
+		}
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("unknown constant kind %d", kind)
+		}
+		return nil
+	}
+}
+
+// validate checks that the VM can run the instructions of bytecode and
+// of its functions without reading past their end, indexing outside
+// the constants, locals and free variables or popping more values off
+// the stack than were pushed. The checksum only catches accidental
+// changes. Builtin indices and the types of the values are checked by
+// the VM, which runs with the builtins it is given.
+func validate(bytecode *Bytecode) error {
+	// the main instructions are checked as a function without locals
+	// or free variables, at constant index -1
+	indices := []int{-1}
+	functions := []*object.CompiledFunction{{Instructions: bytecode.Instructions}}
+	for i, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			indices = append(indices, i)
+			functions = append(functions, fn)
+		}
+	}
+	name := func(index int) string {
+		if index < 0 {
+			return "main instructions"
+		}
+		return fmt.Sprintf("constant %d", index)
+	}
+
+	for i, fn := range functions {
+		if fn.NumParameters > fn.NumLocals {
+			return fmt.Errorf("%s: %d parameters but %d locals",
+				name(indices[i]), fn.NumParameters, fn.NumLocals)
+		}
+		err := validateInstructions(fn, bytecode.Constants)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name(indices[i]), err)
+		}
+	}
+
+	numFree := closedOver(functions)
+	for i, fn := range functions {
+		err := validateFree(fn.Instructions, numFree[indices[i]])
+		if err == nil {
+			err = validateStack(fn.Instructions)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", name(indices[i]), err)
+		}
+	}
+	return nil
+}
+
+// validateInstructions checks the instructions of fn, see validate
+func validateInstructions(fn *object.CompiledFunction, constants []object.Object) error {
+	ins := fn.Instructions
+	starts := map[int]bool{len(ins): true}
+	jumps := map[int]int{}
+	for pos := 0; pos < len(ins); {
+		starts[pos] = true
+		def, err := code.Lookup(ins[pos])
+		if err != nil {
+			return fmt.Errorf("offset %d: %s", pos, err)
+		}
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if pos+1+width > len(ins) {
+			return fmt.Errorf("offset %d: %s: %s", pos, def.Name, ErrTruncated)
+		}
+		operands, read := code.ReadOperands(def, ins[pos+1:])
+
+		switch code.Opcode(ins[pos]) {
+		case code.OpConstant:
+			if operands[0] >= len(constants) {
+				return fmt.Errorf("offset %d: constant %d out of range", pos, operands[0])
+			}
+		case code.OpClosure:
+			if operands[0] >= len(constants) {
+				return fmt.Errorf("offset %d: constant %d out of range", pos, operands[0])
+			}
+			if _, ok := constants[operands[0]].(*object.CompiledFunction); !ok {
+				return fmt.Errorf("offset %d: constant %d is not a function", pos, operands[0])
+			}
+		case code.OpGetLocal, code.OpSetLocal, code.OpGetLocalCell:
+			if operands[0] >= fn.NumLocals {
+				return fmt.Errorf("offset %d: local %d out of range", pos, operands[0])
+			}
+		case code.OpJump, code.OpJumpNotTruthy, code.OpJumpNotTruthyOrPop,
+			code.OpJumpTruthyOrPop, code.OpIteratorNext:
+			jumps[pos] = operands[0]
+		}
+		pos += 1 + read
+	}
+	for pos, target := range jumps {
+		if !starts[target] {
+			return fmt.Errorf("offset %d: jump to %d is not an instruction", pos, target)
+		}
+	}
+	return nil
+}
+
+// closedOver returns the number of free variables that every OpClosure
+// of the functions captures, by constant index
+func closedOver(functions []*object.CompiledFunction) map[int]int {
+	numFree := map[int]int{}
+	for _, fn := range functions {
+		forEachInstruction(fn.Instructions, func(pos int, op code.Opcode, operands []int) {
+			if op != code.OpClosure {
+				return
+			}
+			if n, ok := numFree[operands[0]]; !ok || operands[1] < n {
+				numFree[operands[0]] = operands[1]
+			}
+		})
+	}
+	return numFree
+}
+
+// validateFree checks that ins only reads the first numFree free
+// variables
+func validateFree(ins code.Instructions, numFree int) error {
+	var err error
+	forEachInstruction(ins, func(pos int, op code.Opcode, operands []int) {
+		switch op {
+		case code.OpGetFree, code.OpSetFree, code.OpGetFreeCell:
+			if operands[0] >= numFree && err == nil {
+				err = fmt.Errorf("offset %d: free variable %d out of range", pos, operands[0])
+			}
+		}
+	})
+	return err
+}
+
+// validateStack follows every path through ins and checks that no
+// instruction pops more values than were pushed before it
+func validateStack(ins code.Instructions) error {
+	// the lowest stack height each instruction is reached with
+	heights := map[int]int{0: 0}
+	work := []int{0}
+	for len(work) > 0 {
+		pos := work[len(work)-1]
+		work = work[:len(work)-1]
+		if pos == len(ins) {
+			continue
+		}
+
+		op := code.Opcode(ins[pos])
+		def, _ := code.Lookup(ins[pos])
+		operands, read := code.ReadOperands(def, ins[pos+1:])
+		height := heights[pos]
+		pops, pushes := stackEffect(op, operands)
+		if height < pops {
+			return fmt.Errorf("offset %d: %s: stack height %d, needs %d",
+				pos, def.Name, height, pops)
+		}
+
+		next := pos + 1 + read
+		var successors [][2]int
+		switch op {
+		case code.OpReturnValue, code.OpReturn:
+		case code.OpJump:
+			successors = [][2]int{{operands[0], height}}
+		case code.OpJumpNotTruthy:
+			successors = [][2]int{{operands[0], height - 1}, {next, height - 1}}
+		case code.OpJumpNotTruthyOrPop, code.OpJumpTruthyOrPop:
+			successors = [][2]int{{operands[0], height}, {next, height - 1}}
+		case code.OpIteratorNext:
+			successors = [][2]int{{operands[0], height - 1}, {next, height}}
+		default:
+			successors = [][2]int{{next, height - pops + pushes}}
+		}
+
+		for _, s := range successors {
+			if h, ok := heights[s[0]]; !ok || s[1] < h {
+				heights[s[0]] = s[1]
+				work = append(work, s[0])
+			}
+		}
+	}
+	return nil
+}
+
+// stackEffect returns how many values op pops off the stack and how
+// many it pushes when it falls through to the next instruction
+func stackEffect(op code.Opcode, operands []int) (pops, pushes int) {
+	switch op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull,
+		code.OpGetGlobal, code.OpGetLocal, code.OpGetBuiltin, code.OpGetFree,
+		code.OpCurrentClosure, code.OpGetLocalCell, code.OpGetFreeCell:
+		return 0, 1
+	case code.OpPop, code.OpSetGlobal, code.OpSetLocal, code.OpSetFree,
+		code.OpJumpNotTruthy, code.OpJumpNotTruthyOrPop, code.OpJumpTruthyOrPop,
+		code.OpReturnValue:
+		return 1, 0
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+		code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+		code.OpLessThanOrEqual, code.OpGreaterThanOrEqual,
+		code.OpIndex, code.OpMatchValue:
+		return 2, 1
+	case code.OpMinus, code.OpBang, code.OpIterator, code.OpIteratorNext,
+		code.OpMatchArray:
+		return 1, 1
+	case code.OpArray, code.OpHash, code.OpClosure:
+		return operands[len(operands)-1], 1
+	case code.OpCall, code.OpTailCall, code.OpMatchHash:
+		return operands[0] + 1, 1
+	case code.OpSetIndex:
+		return 3, 1
+	}
+	return 0, 0
+}
+
+// forEachInstruction calls f with every instruction of ins and its
+// offset, after validateInstructions has checked ins
+func forEachInstruction(ins code.Instructions, f func(pos int, op code.Opcode, operands []int)) {
+	for pos := 0; pos < len(ins); {
+		def, _ := code.Lookup(ins[pos])
+		operands, read := code.ReadOperands(def, ins[pos+1:])
+		f(pos, code.Opcode(ins[pos]), operands)
+		pos += 1 + read
+	}
+}
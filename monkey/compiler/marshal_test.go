@@ -0,0 +1,291 @@
+// Package compiler compiler/marshal_test.go
+package compiler
+
+import (
+	"io/ioutil"
+	"monkey/code"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	input := `
+	let greeting = "hello";
+	let ratio = 2.5e-3;
+	let big = -9000000000;
+	let adder = fn(a) {
+		let b = 1;
+		fn(c) { a + b + c }
+	};
+	adder(1)(2);
+	`
+	program := parse(input)
+	compiler := New()
+	compiler.SetFile("adder.mk")
+	err := compiler.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := compiler.Bytecode()
+
+	data, err := Marshal(bytecode)
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unmarshal error: %s", err)
+	}
+
+	if !reflect.DeepEqual(decoded.Instructions, bytecode.Instructions) {
+		t.Errorf("wrong instructions.\nwant=%q\n got=%q",
+			bytecode.Instructions, decoded.Instructions)
+	}
+	if !reflect.DeepEqual(decoded.SourceMap, bytecode.SourceMap) {
+		t.Errorf("wrong source map.\nwant=%+v\n got=%+v",
+			bytecode.SourceMap, decoded.SourceMap)
+	}
+	if len(decoded.Constants) != len(bytecode.Constants) {
+		t.Fatalf("wrong number of constants. want=%d, got=%d",
+			len(bytecode.Constants), len(decoded.Constants))
+	}
+	for i, constant := range bytecode.Constants {
+		if !reflect.DeepEqual(decoded.Constants[i], constant) {
+			t.Errorf("constant %d wrong.\nwant=%+v\n got=%+v",
+				i, constant, decoded.Constants[i])
+		}
+	}
+
+	var fn *object.CompiledFunction
+	for _, constant := range decoded.Constants {
+		if f, ok := constant.(*object.CompiledFunction); ok && f.Name == "adder" {
+			fn = f
+		}
+	}
+	if fn == nil {
+		t.Fatalf("compiled function adder not found in constants")
+	}
+	if fn.Name != "adder" || fn.NumLocals != 2 || fn.NumParameters != 1 {
+		t.Errorf("wrong function. got name=%q locals=%d parameters=%d",
+			fn.Name, fn.NumLocals, fn.NumParameters)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	compiler := New()
+	err := compiler.Compile(parse(`let f = fn(x) { x * 2 }; f("a")`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	data, err := Marshal(compiler.Bytecode())
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+
+	corrupt := func(change func([]byte) []byte) []byte {
+		copied := append([]byte{}, data...)
+		return change(copied)
+	}
+
+	tests := []struct {
+		data          []byte
+		expectedError string
+	}{
+		{[]byte("let x = 1;"), "not a Monkey bytecode file"},
+		{data[:6], "truncated bytecode"},
+		{
+			corrupt(func(b []byte) []byte { b[5] = 99; return b }),
+			"unsupported bytecode version 99, want 2",
+		},
+		{
+			corrupt(func(b []byte) []byte { b[10]++; return b }),
+			"bytecode checksum mismatch",
+		},
+		{
+			corrupt(func(b []byte) []byte { b[len(b)-1]++; return b }),
+			"bytecode checksum mismatch",
+		},
+	}
+
+	for i, tt := range tests {
+		_, err := Unmarshal(tt.data)
+		if err == nil {
+			t.Fatalf("tests[%d] - expected error, got none", i)
+		}
+		if err.Error() != tt.expectedError {
+			t.Errorf("tests[%d] - wrong error. want=%q, got=%q",
+				i, tt.expectedError, err.Error())
+		}
+	}
+}
+
+func TestUnmarshalInvalidInstructions(t *testing.T) {
+	concat := func(ins ...[]byte) code.Instructions {
+		out := code.Instructions{}
+		for _, in := range ins {
+			out = append(out, in...)
+		}
+		return out
+	}
+	function := func(numLocals int, ins ...[]byte) *object.CompiledFunction {
+		return &object.CompiledFunction{Instructions: concat(ins...), NumLocals: numLocals}
+	}
+
+	tests := []struct {
+		bytecode      *Bytecode
+		expectedError string
+	}{
+		{
+			&Bytecode{Instructions: code.Instructions{255}},
+			"main instructions: offset 0: opcode 255 undefined",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpConstant, 1)[:2]},
+			"main instructions: offset 0: OpConstant: truncated bytecode",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpConstant, 1)},
+			"main instructions: offset 0: constant 1 out of range",
+		},
+		{
+			&Bytecode{
+				Instructions: code.Make(code.OpClosure, 0, 0),
+				Constants:    []object.Object{&object.Integer{Value: 1}},
+			},
+			"main instructions: offset 0: constant 0 is not a function",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpGetLocal, 0)},
+			"main instructions: offset 0: local 0 out of range",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpTrue), code.Make(code.OpJump, 2))},
+			"main instructions: offset 1: jump to 2 is not an instruction",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpJump, 100)},
+			"main instructions: offset 0: jump to 100 is not an instruction",
+		},
+		{
+			&Bytecode{
+				Instructions: code.Make(code.OpClosure, 0, 0),
+				Constants: []object.Object{
+					function(1, code.Make(code.OpGetLocal, 1), code.Make(code.OpReturnValue)),
+				},
+			},
+			"constant 0: offset 0: local 1 out of range",
+		},
+		{
+			&Bytecode{
+				Instructions: code.Make(code.OpClosure, 0, 0),
+				Constants: []object.Object{
+					&object.CompiledFunction{NumParameters: 2, NumLocals: 1},
+				},
+			},
+			"constant 0: 2 parameters but 1 locals",
+		},
+		{
+			&Bytecode{
+				Instructions: concat(code.Make(code.OpNull), code.Make(code.OpClosure, 0, 1)),
+				Constants: []object.Object{
+					function(0, code.Make(code.OpGetFree, 1), code.Make(code.OpReturnValue)),
+				},
+			},
+			"constant 0: offset 0: free variable 1 out of range",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpGetFree, 0)},
+			"main instructions: offset 0: free variable 0 out of range",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpPop)},
+			"main instructions: offset 0: OpPop: stack height 0, needs 1",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpTrue), code.Make(code.OpAdd))},
+			"main instructions: offset 1: OpAdd: stack height 1, needs 2",
+		},
+		{
+			// the jump skips the value the OpPop needs
+			&Bytecode{Instructions: concat(
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 5),
+				code.Make(code.OpTrue),
+				code.Make(code.OpPop),
+			)},
+			"main instructions: offset 5: OpPop: stack height 0, needs 1",
+		},
+		{
+			&Bytecode{
+				Instructions: code.Make(code.OpClosure, 0, 0),
+				Constants: []object.Object{
+					function(0, code.Make(code.OpReturnValue)),
+				},
+			},
+			"constant 0: offset 0: OpReturnValue: stack height 0, needs 1",
+		},
+	}
+
+	for i, tt := range tests {
+		data, err := Marshal(tt.bytecode)
+		if err != nil {
+			t.Fatalf("tests[%d] - marshal error: %s", i, err)
+		}
+		_, err = Unmarshal(data)
+		if err == nil {
+			t.Fatalf("tests[%d] - expected error, got none", i)
+		}
+		if err.Error() != tt.expectedError {
+			t.Errorf("tests[%d] - wrong error. want=%q, got=%q",
+				i, tt.expectedError, err.Error())
+		}
+	}
+}
+
+func TestMarshalUnsupportedConstant(t *testing.T) {
+	bytecode := &Bytecode{Constants: []object.Object{&object.Boolean{Value: true}}}
+	_, err := Marshal(bytecode)
+	if err == nil {
+		t.Fatalf("expected error, got none")
+	}
+	expected := "constant 0: cannot marshal constant of type BOOLEAN"
+	if err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, err.Error())
+	}
+}
+
+func TestBytecodeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	compiler := New()
+	err = compiler.Compile(parse(`"file" + "s"`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	filename := filepath.Join(dir, "strings"+FileExtension)
+	err = WriteBytecodeFile(filename, compiler.Bytecode())
+	if err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	bytecode, err := ReadBytecodeFile(filename)
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	err = testConstants(t, []interface{}{"file", "s"}, bytecode.Constants)
+	if err != nil {
+		t.Errorf("testConstants failed: %s", err)
+	}
+
+	_, err = ReadBytecodeFile(filepath.Join(dir, "missing.mbc"))
+	if err == nil {
+		t.Errorf("expected error for missing file, got none")
+	}
+}
@@ -0,0 +1,264 @@
+// Package compiler compiler/optimize.go
+package compiler
+
+import (
+	"monkey/ast"
+	"monkey/token"
+	"strconv"
+)
+
+// SetOptimize turns the optimization of the following compilations
+// on or off. An optimizing compiler folds operators on integer,
+// string and boolean constants, including && and || with a constant
+// left operand, simplifies x * 1, x / 1, x + 0 and x - 0 where x is
+// integer arithmetic, and drops the branches of if expressions that
+// constant conditions rule out.
+func (c *Compiler) SetOptimize(on bool) {
+	c.optimize = on
+}
+
+// foldTree returns a copy of the tree below node with its constant
+// expressions folded. Each node is folded once, after its children.
+func foldTree(node ast.Node) ast.Node {
+	return ast.Modify(ast.Copy(node), func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.PrefixExpression:
+			return foldPrefix(node)
+		case *ast.InfixExpression:
+			return foldInfix(node)
+		default:
+			return node
+		}
+	})
+}
+
+// foldPrefix returns the constant node equal to node, whose operand
+// is folded already, or node itself
+func foldPrefix(node *ast.PrefixExpression) ast.Expression {
+	switch node.Operator {
+	case "!":
+		if truthy, ok := constantTruth(node.Right); ok {
+			return booleanLiteral(node, !truthy)
+		}
+	case "-":
+		if right, ok := node.Right.(*ast.IntegerLiteral); ok {
+			return integerLiteral(node, -right.Value)
+		}
+	}
+	return node
+}
+
+// foldInfix returns the constant node equal to node, whose operands
+// are folded already, the operand that decides a && or || or that an
+// identity leaves, or node itself
+func foldInfix(node *ast.InfixExpression) ast.Expression {
+	// a constant left operand decides whether the right one is
+	// evaluated
+	if node.Operator == "&&" || node.Operator == "||" {
+		if truthy, ok := constantTruth(node.Left); ok {
+			if truthy == (node.Operator == "||") {
+				return node.Left
+			}
+			return node.Right
+		}
+		return node
+	}
+
+	var folded ast.Expression
+	switch left := node.Left.(type) {
+	case *ast.IntegerLiteral:
+		if right, ok := node.Right.(*ast.IntegerLiteral); ok {
+			folded = foldIntegers(node, left.Value, right.Value)
+		}
+	case *ast.StringLiteral:
+		if right, ok := node.Right.(*ast.StringLiteral); ok && node.Operator == "+" {
+			folded = stringLiteral(node, left.Value+right.Value)
+		}
+	case *ast.Boolean:
+		if right, ok := node.Right.(*ast.Boolean); ok {
+			switch node.Operator {
+			case "==":
+				folded = booleanLiteral(node, left.Value == right.Value)
+			case "!=":
+				folded = booleanLiteral(node, left.Value != right.Value)
+			}
+		}
+	}
+	if folded != nil {
+		return folded
+	}
+	if operand := identityOperand(node); operand != nil {
+		return operand
+	}
+	return node
+}
+
+// identityOperand returns the operand of node that an identity such
+// as x * 1 leaves, or nil. The identities only hold for integers:
+// 1 * "a" fails, and -0.0 + 0 is 0.0.
+func identityOperand(node *ast.InfixExpression) ast.Expression {
+	switch {
+	case (node.Operator == "*" || node.Operator == "/") && isIntegerValue(node.Right, 1):
+		if isInteger(node.Left) {
+			return node.Left
+		}
+	case (node.Operator == "+" || node.Operator == "-") && isIntegerValue(node.Right, 0):
+		if isInteger(node.Left) {
+			return node.Left
+		}
+	case node.Operator == "*" && isIntegerValue(node.Left, 1),
+		node.Operator == "+" && isIntegerValue(node.Left, 0):
+		if isInteger(node.Right) {
+			return node.Right
+		}
+	}
+	return nil
+}
+
+// isInteger reports whether node evaluates to an integer, or fails,
+// whatever the values of variables are. Folding leaves such
+// arithmetic only where it divides by zero.
+func isInteger(node ast.Expression) bool {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return node.Operator == "-" && isInteger(node.Right)
+	case *ast.InfixExpression:
+		switch node.Operator {
+		case "+", "-", "*", "/", "%":
+			return isInteger(node.Left) && isInteger(node.Right)
+		}
+	}
+	return false
+}
+
+func isIntegerValue(node ast.Expression, value int64) bool {
+	integer, ok := node.(*ast.IntegerLiteral)
+	return ok && integer.Value == value
+}
+
+// foldIntegers returns the constant result of an operator on two
+// integers as the VM computes it, or nil for division by zero, which
+// is left to fail at run time
+func foldIntegers(node *ast.InfixExpression, left, right int64) ast.Expression {
+	switch node.Operator {
+	case "+":
+		return integerLiteral(node, left+right)
+	case "-":
+		return integerLiteral(node, left-right)
+	case "*":
+		return integerLiteral(node, left*right)
+	case "/":
+		if right != 0 {
+			return integerLiteral(node, left/right)
+		}
+	case "%":
+		if right != 0 {
+			return integerLiteral(node, left%right)
+		}
+	case "<":
+		return booleanLiteral(node, left < right)
+	case "<=":
+		return booleanLiteral(node, left <= right)
+	case ">":
+		return booleanLiteral(node, left > right)
+	case ">=":
+		return booleanLiteral(node, left >= right)
+	case "==":
+		return booleanLiteral(node, left == right)
+	case "!=":
+		return booleanLiteral(node, left != right)
+	}
+	return nil
+}
+
+// constantTruth reports whether node is a constant, and if so
+// whether it is truthy
+func constantTruth(node ast.Expression) (truthy bool, ok bool) {
+	switch node := node.(type) {
+	case *ast.Boolean:
+		return node.Value, true
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// constantBranch returns the branch of node that its folded condition
+// always selects, nil for a missing else branch. It fails if the
+// condition is not constant or the other branch defines names.
+func constantBranch(node *ast.IfExpression) (*ast.BlockStatement, bool) {
+	truthy, ok := constantTruth(node.Condition)
+	if !ok {
+		return nil, false
+	}
+	taken, dropped := node.Consequence, node.Alternative
+	if !truthy {
+		taken, dropped = dropped, taken
+	}
+	if dropped != nil && definesNames(dropped) {
+		return nil, false
+	}
+	return taken, true
+}
+
+// literalToken returns a token of typ holding literal at the
+// position of node, which the folded literal replaces
+func literalToken(node ast.Node, typ token.TokenType, literal string) token.Token {
+	pos := node.Pos()
+	return token.Token{
+		Type:    typ,
+		Literal: literal,
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Offset:  pos.Offset,
+	}
+}
+
+func integerLiteral(node ast.Node, value int64) *ast.IntegerLiteral {
+	literal := strconv.FormatInt(value, 10)
+	return &ast.IntegerLiteral{Token: literalToken(node, token.INT, literal), Value: value}
+}
+
+func stringLiteral(node ast.Node, value string) *ast.StringLiteral {
+	return &ast.StringLiteral{Token: literalToken(node, token.STRING, value), Value: value}
+}
+
+func booleanLiteral(node ast.Node, value bool) *ast.Boolean {
+	typ := token.TokenType(token.FALSE)
+	if value {
+		typ = token.TRUE
+	}
+	return &ast.Boolean{Token: literalToken(node, typ, strconv.FormatBool(value)), Value: value}
+}
+
+// definesNames reports whether compiling block defines names in the
+// enclosing scope, so it cannot be dropped even if it never runs
+func definesNames(block *ast.BlockStatement) bool {
+	v := &definitionFinder{}
+	ast.Walk(block, v)
+	return v.found
+}
+
+// definitionFinder looks for the definitions of a scope, not those
+// of the functions in it
+type definitionFinder struct {
+	found bool
+}
+
+// Visit interface method
+func (v *definitionFinder) Visit(node ast.Node) ast.Visitor {
+	switch node.(type) {
+	case *ast.LetStatement, *ast.ForStatement, *ast.MatchExpression:
+		v.found = true
+		return nil
+	case *ast.FunctionLiteral:
+		return nil
+	}
+	if v.found {
+		return nil
+	}
+	return v
+}
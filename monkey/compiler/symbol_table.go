@@ -1,6 +1,11 @@
 // Package compiler compiler/symbol_table.go
 package compiler
 
+import (
+	"monkey/object"
+	"sort"
+)
+
 // SymbolScope string
 type SymbolScope string
 
@@ -76,6 +81,52 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	return obj, ok
 }
 
+// ResolveOwn func
+func (s *SymbolTable) ResolveOwn(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if !ok || (obj.Scope != GlobalScope && obj.Scope != LocalScope) {
+		return Symbol{}, false
+	}
+	return obj, true
+}
+
+// Symbols returns the symbols of the given scope in the table's
+// own store, ordered by index
+func (s *SymbolTable) Symbols(scope SymbolScope) []Symbol {
+	symbols := []Symbol{}
+	for _, symbol := range s.store {
+		if symbol.Scope == scope {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].Index < symbols[j].Index
+	})
+	return symbols
+}
+
+// Copy returns a copy of the table, so that compiling against
+// the copy leaves s unchanged
+func (s *SymbolTable) Copy() *SymbolTable {
+	c := NewSymbolTable()
+	c.Outer = s.Outer
+	for name, symbol := range s.store {
+		c.store[name] = symbol
+	}
+	c.numDefinitions = s.numDefinitions
+	c.FreeSymbols = append(c.FreeSymbols, s.FreeSymbols...)
+	return c
+}
+
+// originScope follows a free symbol out to the scope that defines it
+func (s *SymbolTable) originScope(symbol Symbol) SymbolScope {
+	for symbol.Scope == FreeScope && s.Outer != nil {
+		symbol = s.FreeSymbols[symbol.Index]
+		s = s.Outer
+	}
+	return symbol.Scope
+}
+
 // DefineBuiltin func
 func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
@@ -95,6 +146,13 @@ func (s *SymbolTable) defineFree(original Symbol) Symbol {
 	return symbol
 }
 
+// DefineBuiltins defines every builtin of r
+func (s *SymbolTable) DefineBuiltins(r *object.Registry) {
+	for i, name := range r.Names() {
+		s.DefineBuiltin(i, name)
+	}
+}
+
 // DefineFunctionName func
 func (s *SymbolTable) DefineFunctionName(name string) Symbol {
 	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
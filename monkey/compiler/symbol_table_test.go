@@ -1,7 +1,11 @@
 // Package compiler compiler/symbol_table_test.go
 package compiler
 
-import "testing"
+import (
+	"monkey/object"
+	"reflect"
+	"testing"
+)
 
 func TestDefine(t *testing.T) {
 	expected := map[string]Symbol{
@@ -192,6 +196,21 @@ func TestDefineResolveBuiltin(t *testing.T) {
 	}
 }
 
+func TestDefineBuiltins(t *testing.T) {
+	r := object.NewRegistry()
+	r.Register("host", &object.Builtin{})
+	global := NewSymbolTable()
+	global.DefineBuiltins(r)
+
+	for i, name := range r.Names() {
+		expected := Symbol{Name: name, Scope: BuiltinScope, Index: i}
+		result, ok := global.Resolve(name)
+		if !ok || result != expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", name, expected, result)
+		}
+	}
+}
+
 func TestResolveFree(t *testing.T) {
 
 	global := NewSymbolTable()
@@ -346,3 +365,48 @@ func TestShadowingFunctionName(t *testing.T) {
 			expected.Name, expected, result)
 	}
 }
+
+func TestSymbols(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(1, "puts")
+	global.Define("b")
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	expectedGlobals := []Symbol{
+		{Name: "b", Scope: GlobalScope, Index: 0},
+		{Name: "a", Scope: GlobalScope, Index: 1},
+	}
+	expectedBuiltins := []Symbol{
+		{Name: "len", Scope: BuiltinScope, Index: 0},
+		{Name: "puts", Scope: BuiltinScope, Index: 1},
+	}
+
+	if !reflect.DeepEqual(global.Symbols(GlobalScope), expectedGlobals) {
+		t.Errorf("wrong globals. want=%+v, got=%+v",
+			expectedGlobals, global.Symbols(GlobalScope))
+	}
+	if !reflect.DeepEqual(global.Symbols(BuiltinScope), expectedBuiltins) {
+		t.Errorf("wrong builtins. want=%+v, got=%+v",
+			expectedBuiltins, global.Symbols(BuiltinScope))
+	}
+}
+
+func TestCopy(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	copied := global.Copy()
+	b := copied.Define("b")
+
+	expected := Symbol{Name: "b", Scope: GlobalScope, Index: 1}
+	if b != expected {
+		t.Errorf("expected b to be %+v, got=%+v", expected, b)
+	}
+	if _, ok := copied.Resolve("a"); !ok {
+		t.Errorf("a not resolvable in the copy")
+	}
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("b resolvable in the original table")
+	}
+}
@@ -2,22 +2,51 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"monkey/ast"
+	"monkey/code"
 	"monkey/object"
+	"monkey/token"
+	"strings"
 )
 
 var (
 	// NULL var
-	NULL = &object.Null{}
+	NULL = object.NULL
 	// TRUE var
-	TRUE = &object.Boolean{Value: true}
+	TRUE = object.TRUE
 	// FALSE var
-	FALSE = &object.Boolean{Value: false}
+	FALSE = object.FALSE
+	// BREAK var
+	BREAK = &object.Break{}
+	// CONTINUE var
+	CONTINUE = &object.Continue{}
 )
 
 // Eval main
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return evalNode(node, env, false)
+}
+
+// evalNode evaluates node, which is in tail position of a function
+// if tail is set: a call to a function there returns an
+// *object.TailCall, so the caller can make it without nesting
+func evalNode(node ast.Node, env *object.Environment, tail bool) object.Object {
+	if meter := env.Meter(); meter != nil {
+		if err := meter.Step(); err != nil {
+			return &object.Error{Message: err.Error(), Err: err}
+		}
+	}
+	result := eval(node, env, tail)
+	if err, ok := result.(*object.Error); ok {
+		markErrorPosition(err, node)
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment, tail bool) object.Object {
 	switch node := node.(type) {
 
 	// Statements
@@ -25,54 +54,87 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalProgram(node, env)
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return evalBlockStatement(node, env, tail)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return evalNode(node.Expression, env, tail)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		// a return in a function is in tail position wherever it is
+		val := evalNode(node.ReturnValue, env, env.Depth() > 0)
+		if isAbrupt(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
-		if isError(val) {
+		if isAbrupt(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
 
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
 	// Expressions
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isAbrupt(left) {
 			return left
 		}
 
+		// only evaluate the right operand when the left one
+		// does not decide the result
+		switch {
+		case node.Operator == "&&" && !isTruthy(left):
+			return left
+		case node.Operator == "||" && isTruthy(left):
+			return left
+		case node.Operator == "&&" || node.Operator == "||":
+			return evalNode(node.Right, env, tail)
+		}
+
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
 
-		return evalInfixExpression(node.Operator, left, right)
+		return evalInfixExpression(node.Operator, left, right, env)
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return evalIfExpression(node, env, tail)
+
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env, tail)
 
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
@@ -80,40 +142,56 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{
+		return allocate(env, &object.Function{
 			Parameters: params,
 			Env:        env,
 			Body:       body,
-		}
+			Name:       node.Name,
+		})
+
+	case *ast.MacroLiteral:
+		return newError("macro literal outside top-level let")
 
 	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			if len(node.Arguments) != 1 {
+				return newError("wrong number of arguments to quote: want=1, got=%d",
+					len(node.Arguments))
+			}
+			return quote(node.Arguments[0], env)
+		}
 		function := Eval(node.Function, env)
-		if isError(function) {
+		if isAbrupt(function) {
 			return function
 		}
 		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		if len(args) == 1 && isAbrupt(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		if fn, ok := function.(*object.Function); ok && tail &&
+			len(args) == len(fn.Parameters) {
+			return &object.TailCall{Fn: fn, Args: args}
+		}
+		return applyFunction(function, args, env)
 
 	case *ast.StringLiteral:
+		// literals are not counted, as the VM keeps them in constants
 		return &object.String{Value: node.Value}
 
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		if len(elements) == 1 && isAbrupt(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return allocate(env, &object.Array{Elements: elements})
 
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isAbrupt(left) {
 			return left
 		}
 		index := Eval(node.Index, env)
-		if isError(index) {
+		if isAbrupt(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
@@ -136,6 +214,7 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 		case *object.ReturnValue:
 			return result.Value
 		case *object.Error:
+			closeErrorFrame(result, object.MainFunctionName)
 			return result
 		}
 	}
@@ -146,15 +225,17 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 func evalBlockStatement(
 	block *ast.BlockStatement,
 	env *object.Environment,
+	tail bool,
 ) object.Object {
 	var result object.Object
 
-	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+	for i, statement := range block.Statements {
+		result = evalNode(statement, env, tail && i == len(block.Statements)-1)
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
+				rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -170,20 +251,160 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+func evalIfExpression(
+	ie *ast.IfExpression,
+	env *object.Environment,
+	tail bool,
+) object.Object {
 	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+	if isAbrupt(condition) {
 		return condition
 	}
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return evalNode(ie.Consequence, env, tail)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return evalNode(ie.Alternative, env, tail)
 	} else {
 		return NULL
 	}
 }
 
+func evalMatchExpression(
+	me *ast.MatchExpression,
+	env *object.Environment,
+	tail bool,
+) object.Object {
+	value := Eval(me.Value, env)
+	if isAbrupt(value) {
+		return value
+	}
+	for _, arm := range me.Arms {
+		bindings := map[string]object.Object{}
+		matched, err := matchPattern(arm.Pattern, value, bindings, env)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		for name, val := range bindings {
+			env.Set(name, val)
+		}
+		return evalNode(arm.Body, env, tail)
+	}
+	return NULL
+}
+
+// matchPattern reports whether value matches pattern, collecting
+// the values of the identifiers in the pattern into bindings
+func matchPattern(
+	pattern ast.Expression,
+	value object.Object,
+	bindings map[string]object.Object,
+	env *object.Environment,
+) (bool, object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			bindings[pattern.Value] = value
+		}
+		return true, nil
+
+	case *ast.ArrayLiteral:
+		array, ok := value.(*object.Array)
+		if !ok || len(array.Elements) != len(pattern.Elements) {
+			return false, nil
+		}
+		for i, el := range pattern.Elements {
+			matched, err := matchPattern(el, array.Elements[i], bindings, env)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case *ast.HashLiteral:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return false, nil
+		}
+		for keyNode, valueNode := range pattern.Pairs {
+			key := Eval(keyNode, env).(object.Hashable)
+			pair, ok := hash.Pairs[key.HashKey()]
+			if !ok {
+				return false, nil
+			}
+			matched, err := matchPattern(valueNode, pair.Value, bindings, env)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+
+	default:
+		literal := Eval(pattern, env)
+		if isError(literal) {
+			return false, literal
+		}
+		return matchValue(literal, value), nil
+	}
+}
+
+// matchValue reports whether a literal pattern and a value are
+// of the same type and equal
+func matchValue(literal, value object.Object) bool {
+	l, ok := literal.(object.Hashable)
+	if !ok {
+		return false
+	}
+	v, ok := value.(object.Hashable)
+	return ok && l.HashKey() == v.HashKey()
+}
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+		result := Eval(ws.Body, env)
+		if result == BREAK {
+			return NULL
+		}
+		if isError(result) || result != nil && result.Type() == object.RETURN_VALUE_OBJ {
+			return result
+		}
+	}
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	collection := Eval(fs.Iterable, env)
+	if isAbrupt(collection) {
+		return collection
+	}
+	iterator, ok := object.NewIterator(collection)
+	if !ok {
+		return newError("cannot iterate over %s", collection.Type())
+	}
+	for {
+		element, ok := iterator.Next()
+		if !ok {
+			return NULL
+		}
+		env.Set(fs.Variable.Value, element)
+		result := Eval(fs.Body, env)
+		if result == BREAK {
+			return NULL
+		}
+		if isError(result) || result != nil && result.Type() == object.RETURN_VALUE_OBJ {
+			return result
+		}
+	}
+}
+
 func evalExpressions(
 	exps []ast.Expression,
 	env *object.Environment,
@@ -191,7 +412,7 @@ func evalExpressions(
 	var result []object.Object
 	for _, e := range exps {
 		evaluated := Eval(e, env)
-		if isError(evaluated) {
+		if isAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -199,15 +420,78 @@ func evalExpressions(
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// EvalContext evaluates node as Eval does, but stops with an error
+// whose Err is an *object.AbortError when the evaluation exceeds
+// limits or ctx is done
+func EvalContext(
+	ctx context.Context,
+	node ast.Node,
+	env *object.Environment,
+	limits object.Limits,
+) object.Object {
+	env.SetMeter(object.NewMeter(ctx, limits))
+	defer env.SetMeter(nil)
+	return Eval(node, env)
+}
+
+// Apply calls fn, a function or builtin, with args from outside of a
+// program, e.g. a function that a program evaluated before has defined.
+// Builtins run in the context of env, and the call is counted by its
+// meter.
+func Apply(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	result := applyFunction(fn, args, env)
+	if err, ok := result.(*object.Error); ok {
+		// there is no caller frame
+		if n := len(err.Stack); n > 0 && err.Stack[n-1] == (object.StackFrame{}) {
+			err.Stack = err.Stack[:n-1]
+		}
+	}
+	return result
+}
+
+func applyFunction(
+	fn object.Object,
+	args []object.Object,
+	env *object.Environment,
+) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d",
+				len(fn.Parameters), len(args))
+		}
+		maxDepth := object.DefaultMaxDepth
+		if meter := env.Meter(); meter != nil {
+			if err := meter.Allocate(object.FrameSize); err != nil {
+				return &object.Error{Message: err.Error(), Err: err}
+			}
+			maxDepth = meter.MaxDepth()
+		}
+		depth := env.Depth() + 1
+		if depth > maxDepth {
+			return newError("maximum recursion depth exceeded in %s", functionName(fn))
+		}
+		// trampoline: the tail calls of the function are made
+		// here, at its depth
+		for {
+			extendedEnv := extendFunctionEnv(fn, args)
+			extendedEnv.SetDepth(depth)
+			evaluated := evalNode(fn.Body, extendedEnv, true)
+			if err, ok := evaluated.(*object.Error); ok {
+				closeErrorFrame(err, functionName(fn))
+				err.Stack = append(err.Stack, object.StackFrame{})
+				return err
+			}
+			result := unwrapReturnValue(evaluated)
+			tailCall, ok := result.(*object.TailCall)
+			if !ok {
+				return result
+			}
+			fn, args = tailCall.Fn, tailCall.Args
+		}
 	case *object.Builtin:
-		if result := fn.Fn(args...); result != nil {
-			return result
+		if result := fn.Fn(env.Context(), args...); result != nil {
+			return allocate(env, result)
 		}
 		return NULL
 	default:
@@ -215,6 +499,27 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
+// functionName names fn in stack traces
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return object.AnonymousFunctionName
+	}
+	return fn.Name
+}
+
+// allocate counts obj as allocated by the meter of env, returning an
+// error instead of obj if the memory limit is exceeded
+func allocate(env *object.Environment, obj object.Object) object.Object {
+	meter := env.Meter()
+	if meter == nil {
+		return obj
+	}
+	if err := meter.Allocate(object.SizeOf(obj)); err != nil {
+		return &object.Error{Message: err.Error(), Err: err}
+	}
+	return obj
+}
+
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
@@ -246,10 +551,16 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+func evalInfixExpression(
+	operator string,
+	left, right object.Object,
+	env *object.Environment,
+) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -257,7 +568,7 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
+		return evalStringInfixExpression(operator, left, right, env)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -274,11 +585,23 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -288,12 +611,63 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
-func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat converts an Integer or Float to a float64
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
+func evalStringInfixExpression(
+	operator string,
+	left, right object.Object,
+	env *object.Environment,
+) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
 	switch operator {
 	case "+":
-		return &object.String{Value: leftVal + rightVal}
+		return allocate(env, &object.String{Value: leftVal + rightVal})
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -324,17 +698,45 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// markErrorPosition records the position of the innermost node
+// that produced the error in the frame being unwound
+func markErrorPosition(err *object.Error, node ast.Node) {
+	if len(err.Stack) == 0 {
+		err.Stack = append(err.Stack, object.StackFrame{})
+	}
+	frame := &err.Stack[len(err.Stack)-1]
+	if frame.Location.Span.Start.IsValid() {
+		return
+	}
+	pos := node.Pos()
+	frame.Location = code.Location{Span: token.Span{Start: pos, End: pos}}
+}
+
+// closeErrorFrame names the function of the frame being unwound
+func closeErrorFrame(err *object.Error, name string) {
+	if len(err.Stack) == 0 {
+		err.Stack = append(err.Stack, object.StackFrame{})
+	}
+	frame := &err.Stack[len(err.Stack)-1]
+	if frame.Function == "" {
+		frame.Function = name
+	}
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
@@ -342,6 +744,19 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+// isAbrupt reports whether obj is an error or a return, break or
+// continue, which end the evaluation of the expressions around it
+func isAbrupt(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	}
+	return false
+}
+
 func evalIdentifier(
 	node *ast.Identifier,
 	env *object.Environment,
@@ -350,7 +765,7 @@ func evalIdentifier(
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := env.Builtins().Lookup(node.Value); ok {
 		return builtin
 	}
 	return newError("identifier not found: " + node.Value)
@@ -378,6 +793,111 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+func evalAssignExpression(
+	node *ast.AssignExpression,
+	env *object.Environment,
+) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if node.Operator != "=" {
+			current = evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+		val := Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		if current != nil {
+			val = evalCompoundOperator(node.Operator, current, val, env)
+			if isError(val) {
+				return val
+			}
+		}
+		if _, ok := env.Assign(target.Value, val); !ok {
+			if _, ok := env.Builtins().Lookup(target.Value); ok {
+				return newError("cannot assign to builtin %s", target.Value)
+			}
+			return newError("identifier not found: " + target.Value)
+		}
+		return val
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+		val := Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		if node.Operator != "=" {
+			current := evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+			val = evalCompoundOperator(node.Operator, current, val, env)
+			if isError(val) {
+				return val
+			}
+		}
+		return evalIndexAssignment(left, index, val, env)
+
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+func evalCompoundOperator(
+	operator string,
+	left, right object.Object,
+	env *object.Environment,
+) object.Object {
+	return evalInfixExpression(strings.TrimSuffix(operator, "="), left, right, env)
+}
+
+func evalIndexAssignment(
+	left, index, val object.Object,
+	env *object.Environment,
+) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("index assignment not supported: %s[%s]",
+				left.Type(), index.Type())
+		}
+		if idx.Value < 0 || idx.Value >= int64(len(left.Elements)) {
+			return newError("index out of range: %d", idx.Value)
+		}
+		left.Elements[idx.Value] = val
+		return val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		hashKey := key.HashKey()
+		if _, ok := left.Pairs[hashKey]; !ok {
+			if meter := env.Meter(); meter != nil {
+				if err := meter.Allocate(object.HashPairSize); err != nil {
+					return &object.Error{Message: err.Error(), Err: err}
+				}
+			}
+		}
+		left.Pairs[hashKey] = object.HashPair{Key: index, Value: val}
+		return val
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
 func evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
@@ -385,7 +905,7 @@ func evalHashLiteral(
 	pairs := make(map[object.HashKey]object.HashPair)
 	for keyNode, valueNode := range node.Pairs {
 		key := Eval(keyNode, env)
-		if isError(key) {
+		if isAbrupt(key) {
 			return key
 		}
 		hashKey, ok := key.(object.Hashable)
@@ -393,13 +913,13 @@ func evalHashLiteral(
 			return newError("unusable as hash key: %s", key.Type())
 		}
 		value := Eval(valueNode, env)
-		if isError(value) {
+		if isAbrupt(value) {
 			return value
 		}
 		hashed := hashKey.HashKey()
 		pairs[hashed] = object.HashPair{Key: key, Value: value}
 	}
-	return &object.Hash{Pairs: pairs}
+	return allocate(env, &object.Hash{Pairs: pairs})
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
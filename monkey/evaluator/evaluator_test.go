@@ -2,10 +2,15 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -37,6 +42,48 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"1.5 + 2.25", 3.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 - 2.5", 7.5},
+		{"1.5 * 4", 6.0},
+		{"7 / 2.0", 3.5},
+		{"-2.5", -2.5},
+		{"2e3 / 4", 500.0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalMixedComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1 == 1.0", true},
+		{"1.5 != 1.5", false},
+		{"0.1 + 0.2 > 0.3", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -70,6 +117,47 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestComparisonAndLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7.5 % 2", 1.5},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"2.5 >= 2", true},
+		{"1.5 <= 1", false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 2", 2},
+		{"0 || 2", 0},
+		{"false && (1 / 0 == 0)", false},
+		{"true || (1 % 0 == 0)", true},
+		{"1 < 2 && 2 < 3 || false", true},
+		{"let x = 5; x > 0 && x < 10", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -117,6 +205,63 @@ func TestIfElseExpressions(t *testing.T) {
 	}
 }
 
+func TestElseIfExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (1 > 2) { 10 } else if (1 < 2) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (1 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (1 > 3) { 20 }", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"match (1) { 1 => 10, 2 => 20 }", 10},
+		{"match (2) { 1 => 10, 2 => 20 }", 20},
+		{"match (3) { 1 => 10, 2 => 20 }", nil},
+		{"match (3) { 1 => 10, n => n * 100 }", 300},
+		{"match (-1) { -1 => 1, _ => 2 }", 1},
+		{"match (1.5) { 1 => 1, 1.5 => 2 }", 2},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{"match (true) { false => 1, true => 2 }", 2},
+		{`match (1) { "1" => 1, true => 2, _ => 3 }`, 3},
+		{"match ([1, 2]) { [a] => a, [a, b, c] => a, [a, b] => a + b }", 3},
+		{"match ([1, [2, 3]]) { [1, [x, 4]] => 0, [1, [x, 3]] => x }", 2},
+		{`match ({"x": 1, "y": 2}) { {"z": z} => z, {"x": x, "y": y} => x * 10 + y }`, 12},
+		{`match ({"k": [1, 2]}) { {"k": [a, b]} => a + b }`, 3},
+		{"match ({}) { {} => 1 }", 1},
+		{"match (5) { [a] => 1, {} => 2, _ => 3 }", 3},
+		{"let f = fn(n) { match (n) { 0 => 1, _ => n * f(n - 1) } }; f(5)", 120},
+		{"let x = 1; match (5) { x => x }; x", 5},
+		{"match (1) { a => match (a + 1) { b => a + b } }", 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -164,6 +309,10 @@ func TestErrorHandling(t *testing.T) {
 			"5 + true; 5;",
 			"type mismatch: INTEGER + BOOLEAN",
 		},
+		{
+			"fn(a, b) { a + b }(1)",
+			"wrong number of arguments: want=2, got=1",
+		},
 		{
 			"-true",
 			"unknown operator: -BOOLEAN",
@@ -201,6 +350,10 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "Monkey"}[fn(x){ x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"true && 1 % 0",
+			"division by zero",
+		},
 	}
 
 	for _, tt := range tests {
@@ -219,6 +372,119 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorStackTraces(t *testing.T) {
+	input := `let inner = fn(x) {
+  x + true
+};
+let outer = fn(y) {
+  inner(y)
+};
+outer(1);`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned, got=%T(%+v)",
+			evaluated, evaluated)
+	}
+
+	// outer calls inner in tail position, so inner replaces its frame
+	expected := `Traceback (most recent call last):
+  line 7, column 6, in <main>
+  line 2, column 5, in inner
+type mismatch: INTEGER + BOOLEAN`
+	if errObj.Traceback() != expected {
+		t.Errorf("wrong traceback, expected=\n%s\ngot=\n%s",
+			expected, errObj.Traceback())
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x %= 4; x", 2},
+		{"let x = 1; let y = 1; x = y = 3; x + y", 6},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let i = 0; while (i < 5) { i += 1; }; i", 5},
+		{"let a = [1, 2, 3]; a[2] *= 10; a[2]", 30},
+		{"let a = [1, 2]; let b = a; b[0] = 9; a[0]", 9},
+		{`let h = {"a": 1}; h["a"] = 2; h["b"] = 3; h["a"] + h["b"]`, 5},
+		{"let f = fn(n) { n += 1; n }; f(1)", 2},
+		{"let counter = fn() { let count = 0; fn() { count += 1; count } }; let c = counter(); c(); c(); c()", 3},
+		{"let f = fn() { let x = 1; let set = fn(v) { x = v }; set(5); x }; f()", 5},
+		{"x = 1", "identifier not found: x"},
+		{"len = 1", "cannot assign to builtin len"},
+		{"let a = [1]; a[3] = 1", "index out of range: 3"},
+		{`let s = "ab"; s[0] = "c"`, "index assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("String has wrong value. got=%q, want=%q", obj.Value, expected)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; let sum = 0; while (i < 5) { let sum = sum + i; let i = i + 1; }; sum", 10},
+		{"let i = 0; while (i < 10000) { let i = i + 1; }; i", 10000},
+		{"let i = 0; while (true) { let i = i + 1; if (i == 3) { break; } }; i", 3},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 0) { continue; } let sum = sum + x; }; sum", 4},
+		{`let s = ""; for (k in {"b": 2, "a": 1}) { let s = s + k; }; s`, "ab"},
+		{"let n = 0; for (x in [1, 2]) { for (y in [1, 2, 3]) { if (y == 2) { break; } let n = n + 1; } }; n", 2},
+		{"let f = fn(xs) { let sum = 0; for (x in xs) { let sum = sum + x; }; sum }; f([1, 2, 3])", 6},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x; } } }; f()", 2},
+		{"while (false) { 1 }", nil},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("String has wrong value. got=%q, want=%q", obj.Value, expected)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+			}
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -338,6 +604,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("hello world")`, 11},
 		{`len(1)`, "argument to 'len' not supported, got=INTEGER"},
 		{`len("one","two")`, "wrong number of arguments, got=2, want=1"},
+		{`len(push([1], 2))`, 2},
+		{`gets(1)`, "wrong number of arguments, got=1, want=0"},
 	}
 
 	for _, tt := range tests {
@@ -360,6 +628,202 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestBuiltinContext(t *testing.T) {
+	input := `
+	let greet = fn(name) { puts("hello " + name); };
+	greet(gets());
+	eputs(gets(), 2);
+	puts(gets());
+	`
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	var stdout, stderr bytes.Buffer
+	ctx := &object.Context{
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Stdin:  strings.NewReader("monkey\r\nwarning\n"),
+	}
+	env := object.NewEnvironment()
+	env.SetContext(ctx)
+	Eval(program, env)
+
+	if stdout.String() != "hello monkey\nnull\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+	if stderr.String() != "warning\n2\n" {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+}
+
+func TestBuiltinRegistry(t *testing.T) {
+	r := object.NewRegistry()
+	r.RegisterFunc("double", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+
+	program := parser.New(lexer.New("double(len([1, 2, 3]))")).ParseProgram()
+	env := object.NewEnvironment()
+	env.SetBuiltins(r)
+	testIntegerObject(t, Eval(program, env), 6)
+
+	// the standard builtins have no double
+	errObj, ok := Eval(program, object.NewEnvironment()).(*object.Error)
+	if !ok || errObj.Message != "identifier not found: double" {
+		t.Errorf("expected identifier not found, got %+v", errObj)
+	}
+}
+
+func TestRecursionDepth(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   object.Limits
+		expected string
+	}{
+		{"let down = fn(n) { if (n == 0) { 0 } else { 1 + down(n - 1) } };\ndown(5000)",
+			object.Limits{}, ""},
+		{"let f = fn(n) { 1 + f(n + 1) };\nf(0)", object.Limits{MaxDepth: 10},
+			`Traceback (most recent call last):
+  line 2, column 2, in <main>
+  line 1, column 22, in f
+  line 1, column 22, in f
+  line 1, column 22, in f
+  [previous frame repeated 7 more times]
+maximum recursion depth exceeded in f`},
+		{"let f = fn(n) { 1 + f(n + 1) };\nf(0)", object.Limits{}, "maximum recursion depth exceeded in f"},
+		{"fn() { let g = fn() { 1 + g() }; 1 + g() }()", object.Limits{MaxDepth: 3},
+			`Traceback (most recent call last):
+  line 1, column 43, in <main>
+  line 1, column 39, in <anonymous>
+  line 1, column 28, in g
+  line 1, column 28, in g
+maximum recursion depth exceeded in g`},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		var result object.Object
+		if tt.limits == (object.Limits{}) {
+			result = Eval(program, object.NewEnvironment())
+		} else {
+			result = EvalContext(context.Background(), program, object.NewEnvironment(), tt.limits)
+		}
+
+		errObj, isErr := result.(*object.Error)
+		if tt.expected == "" {
+			if isErr {
+				t.Errorf("%q: unexpected error: %s", tt.input, errObj.Message)
+			}
+			continue
+		}
+		if !isErr {
+			t.Errorf("%q: expected an error, got %+v", tt.input, result)
+			continue
+		}
+		if !strings.HasSuffix(errObj.Traceback(), tt.expected) {
+			t.Errorf("%q: wrong traceback, expected=\n%s\ngot=\n%s",
+				tt.input, tt.expected, errObj.Traceback())
+		}
+		if tt.limits.MaxDepth == 0 && len(errObj.Stack) != object.DefaultMaxDepth+1 {
+			t.Errorf("%q: wrong stack depth. want=%d, got=%d",
+				tt.input, object.DefaultMaxDepth+1, len(errObj.Stack))
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let loop = fn(i, acc) { if (i == 0) { acc } else { loop(i - 1, acc + i) } };\n" +
+			"loop(100000, 0)", "5000050000"},
+		{"let odd = 0;\n" +
+			"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };\n" +
+			"odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };\n" +
+			"even(100001)", "false"},
+		{"let sum = fn(arr, acc) { if (len(arr) == 0) { acc } else { sum(rest(arr), acc + first(arr)) } };\n" +
+			"let a = []; let i = 0; while (i < 1000) { a = push(a, i); i += 1 };\n" +
+			"sum(a, 0)", "499500"},
+		{"let f = fn(n) { while (true) { if (n == 0) { return \"done\" }; return f(n - 1) } };\n" +
+			"f(100000)", "done"},
+		{"let f = fn(n) { n > 100000 || f(n + 1) };\nf(0)", "true"},
+		{"let collect = fn(i, fs) { if (i == 3) { fs } else { " +
+			"let j = i; let g = fn() { j }; j = j * 10; collect(i + 1, push(fs, g)) } };\n" +
+			"let fs = collect(0, []); [fs[0](), fs[1](), fs[2]()]", "[0, 10, 20]"},
+		{"let f = fn(a) { len(a) };\nf([1, 2])", "2"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } };\nf(5)", "5"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		// tail calls run at constant depth
+		result := EvalContext(context.Background(), program, object.NewEnvironment(),
+			object.Limits{MaxDepth: 10})
+		if result.Inspect() != tt.expected {
+			t.Errorf("%q: wrong result. want=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		limits   object.Limits
+		expected error
+	}{
+		{"let i = 0; while (i < 10) { i += 1 }; i", context.Background(),
+			object.Limits{MaxInstructions: 1000}, nil},
+		{"while (true) { }", context.Background(),
+			object.Limits{MaxInstructions: 1000}, object.ErrInstructionLimit},
+		{"let f = fn() { while (true) { } }; f()", context.Background(),
+			object.Limits{Timeout: 10 * time.Millisecond}, object.ErrTimeout},
+		{"while (true) { }", canceled, object.Limits{}, context.Canceled},
+		{"let a = []; let i = 0; while (i < 10) { a = push(a, i); i += 1 }",
+			context.Background(), object.Limits{MaxMemory: 1 << 20}, nil},
+		{"let a = []; while (true) { a = push(a, 1) }", context.Background(),
+			object.Limits{MaxMemory: 1 << 20}, object.ErrMemoryLimit},
+		{"let s = \"ab\"; while (true) { s = s + s }", context.Background(),
+			object.Limits{MaxMemory: 1 << 20}, object.ErrMemoryLimit},
+		{"let f = fn(n) { {n: [n]} }; while (true) { f(1) }", context.Background(),
+			object.Limits{MaxMemory: 1 << 20}, object.ErrMemoryLimit},
+		{"let h = {}; let i = 0; while (true) { h[i] = i; i += 1 }", context.Background(),
+			object.Limits{MaxMemory: 1 << 20}, object.ErrMemoryLimit},
+		{"let h = {}; let i = 0; while (i < 100000) { h[0] = i; i += 1 }", context.Background(),
+			object.Limits{MaxMemory: 1 << 10}, nil},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+		result := EvalContext(tt.ctx, program, env, tt.limits)
+
+		if env.Meter() != nil {
+			t.Errorf("%q: meter left on the environment", tt.input)
+		}
+		errObj, isErr := result.(*object.Error)
+		if tt.expected == nil {
+			if isErr {
+				t.Errorf("%q: unexpected error: %s", tt.input, errObj.Message)
+			}
+			continue
+		}
+		if !isErr {
+			t.Errorf("%q: expected an error, got %+v", tt.input, result)
+			continue
+		}
+		if _, ok := errObj.Err.(*object.AbortError); !ok || !errors.Is(errObj.Err, tt.expected) {
+			t.Errorf("%q: wrong error. want=%v, got=%v", tt.input, tt.expected, errObj.Err)
+		}
+		if errObj.Message != tt.expected.Error() {
+			t.Errorf("%q: wrong message. got=%q", tt.input, errObj.Message)
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := `[1, 2 * 2, 3 + 3]`
 	evaluated := testEval(input)
@@ -543,6 +1007,20 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float, got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value, got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
@@ -0,0 +1,314 @@
+// Package interpreter interpreter/interpreter.go
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"strings"
+)
+
+// engines that run the programs of an Interpreter
+const (
+	EngineVM   = "vm"
+	EngineEval = "eval"
+)
+
+// Options configure an Interpreter
+type Options struct {
+	// Engine is EngineVM, the default, or EngineEval
+	Engine string
+	// Context holds the streams of the builtins,
+	// object.StandardContext by default
+	Context *object.Context
+	// File names the source in errors
+	File string
+	// Limits bound each Eval and Call
+	Limits object.Limits
+	// Optimize turns on the optimization of the bytecode of the
+	// VM engine, see compiler.SetOptimize
+	Optimize bool
+}
+
+// ParseError is returned for a source that does not parse
+type ParseError struct {
+	File   string
+	Errors []string
+}
+
+// Error interface method
+func (pe *ParseError) Error() string {
+	var out strings.Builder
+	for i, msg := range pe.Errors {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		if pe.File != "" {
+			out.WriteString(pe.File + ":")
+		}
+		out.WriteString(msg)
+	}
+	return out.String()
+}
+
+// Interpreter runs Monkey programs for a Go host. The globals and
+// macros a program defines persist, so later calls can use them.
+type Interpreter struct {
+	engine   string
+	context  *object.Context
+	file     string
+	limits   object.Limits
+	optimize bool
+	builtins *object.Registry
+
+	macroEnv *object.Environment
+
+	// state of the VM
+	constants   []object.Object
+	globals     []object.Object
+	symbolTable *compiler.SymbolTable
+
+	// state of the evaluator
+	env *object.Environment
+}
+
+// New returns an Interpreter configured by opts
+func New(opts Options) (*Interpreter, error) {
+	in := &Interpreter{
+		engine:   opts.Engine,
+		context:  opts.Context,
+		file:     opts.File,
+		limits:   opts.Limits,
+		optimize: opts.Optimize,
+		builtins: object.NewRegistry(),
+		macroEnv: object.NewEnvironment(),
+	}
+	if in.engine == "" {
+		in.engine = EngineVM
+	}
+	if in.context == nil {
+		in.context = object.StandardContext
+	}
+
+	switch in.engine {
+	case EngineVM:
+		in.constants = []object.Object{}
+		in.globals = make([]object.Object, vm.GlobalSize)
+		in.symbolTable = compiler.NewSymbolTable()
+		in.symbolTable.DefineBuiltins(in.builtins)
+	case EngineEval:
+		in.env = object.NewEnvironment()
+		in.env.SetContext(in.context)
+		in.env.SetBuiltins(in.builtins)
+	default:
+		return nil, fmt.Errorf("unknown engine %q, use %q or %q",
+			opts.Engine, EngineVM, EngineEval)
+	}
+	return in, nil
+}
+
+// Eval runs src and returns the value of its last statement, or
+// null if that is not an expression. The error is a *ParseError, a
+// compilation error, an *object.RuntimeError, or an
+// *object.AbortError if the run exceeds Options.Limits.
+func (in *Interpreter) Eval(src string) (object.Object, error) {
+	return in.EvalContext(context.Background(), src)
+}
+
+// EvalContext runs src as Eval does, and stops it with an
+// *object.AbortError when ctx is done
+func (in *Interpreter) EvalContext(ctx context.Context, src string) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{File: in.file, Errors: p.Errors()}
+	}
+
+	evaluator.DefineMacros(program, in.macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, in.macroEnv)
+	if err != nil {
+		return nil, err
+	}
+	program = expanded.(*ast.Program)
+
+	if in.engine == EngineEval {
+		return in.result(evaluator.EvalContext(ctx, program, in.env, in.limits))
+	}
+
+	comp := compiler.NewWithState(in.symbolTable, in.constants)
+	comp.SetFile(in.file)
+	comp.SetOptimize(in.optimize)
+	err = comp.Compile(program)
+	if err != nil {
+		return nil, err
+	}
+	code := comp.Bytecode()
+	in.constants = code.Constants
+
+	machine := in.newVM(code)
+	err = machine.RunContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the last popped element is stale unless the
+	// program ends with an expression
+	n := len(program.Statements)
+	if n == 0 {
+		return vm.Null, nil
+	}
+	if _, ok := program.Statements[n-1].(*ast.ExpressionStatement); !ok {
+		return vm.Null, nil
+	}
+	return machine.LastPoppedStackElem(), nil
+}
+
+// Call calls the global function name with args and returns its result
+func (in *Interpreter) Call(name string, args ...object.Object) (object.Object, error) {
+	return in.CallContext(context.Background(), name, args...)
+}
+
+// CallContext calls a function as Call does, and stops it with an
+// *object.AbortError when ctx is done
+func (in *Interpreter) CallContext(
+	ctx context.Context,
+	name string,
+	args ...object.Object,
+) (object.Object, error) {
+	fn, ok := in.GetGlobal(name)
+	if !ok {
+		return nil, fmt.Errorf("undefined function %s", name)
+	}
+	switch fn.(type) {
+	case *object.Closure, *object.Function, *object.Builtin:
+	default:
+		return nil, fmt.Errorf("%s is not a function: %s", name, fn.Type())
+	}
+
+	callArgs := make([]object.Object, len(args))
+	for i, arg := range args {
+		callArgs[i] = canonical(arg)
+	}
+	if in.engine == EngineEval {
+		in.env.SetMeter(object.NewMeter(ctx, in.limits))
+		defer in.env.SetMeter(nil)
+		return in.result(evaluator.Apply(fn, callArgs, in.env))
+	}
+	machine := in.newVM(&compiler.Bytecode{Constants: in.constants})
+	return machine.CallContext(ctx, fn, callArgs...)
+}
+
+// RegisterBuiltin makes builtin callable under name by the programs
+// of the interpreter, and no other. It replaces the builtin of that
+// name, but a global of that name hides it.
+func (in *Interpreter) RegisterBuiltin(name string, builtin *object.Builtin) error {
+	err := in.builtins.Register(name, builtin)
+	if err != nil {
+		return err
+	}
+	in.defineBuiltin(name)
+	return nil
+}
+
+// RegisterFunc registers a host function as the builtin name,
+// as RegisterBuiltin does
+func (in *Interpreter) RegisterFunc(name string, fn func(args ...object.Object) object.Object) error {
+	err := in.builtins.RegisterFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	in.defineBuiltin(name)
+	return nil
+}
+
+// defineBuiltin makes a registered builtin known to the compiler
+func (in *Interpreter) defineBuiltin(name string) {
+	if in.engine != EngineVM {
+		return
+	}
+	if _, ok := in.symbolTable.ResolveOwn(name); !ok {
+		index, _ := in.builtins.Index(name)
+		in.symbolTable.DefineBuiltin(index, name)
+	}
+}
+
+// SetGlobal binds name to value, as a let statement of the
+// program would
+func (in *Interpreter) SetGlobal(name string, value object.Object) {
+	value = canonical(value)
+	if in.engine == EngineEval {
+		in.env.Set(name, value)
+		return
+	}
+
+	symbol, ok := in.symbolTable.ResolveOwn(name)
+	if !ok {
+		symbol = in.symbolTable.Define(name)
+	}
+	in.globals[symbol.Index] = value
+}
+
+// GetGlobal returns the value of the global name, and whether
+// it is defined
+func (in *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	if in.engine == EngineEval {
+		return in.env.Get(name)
+	}
+
+	symbol, ok := in.symbolTable.ResolveOwn(name)
+	if !ok || in.globals[symbol.Index] == nil {
+		return nil, false
+	}
+	return in.globals[symbol.Index], true
+}
+
+func (in *Interpreter) newVM(code *compiler.Bytecode) *vm.VM {
+	machine := vm.NewWithGlobalsStore(code, in.globals)
+	machine.SetContext(in.context)
+	machine.SetBuiltins(in.builtins)
+	machine.SetLimits(in.limits)
+	return machine
+}
+
+// result converts the result of the evaluator to that of Eval
+func (in *Interpreter) result(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		for i := range errObj.Stack {
+			errObj.Stack[i].Location.File = in.file
+		}
+		if abortErr, ok := errObj.Err.(*object.AbortError); ok {
+			abortErr.Stack = errObj.Stack
+			return nil, abortErr
+		}
+		return nil, &object.RuntimeError{
+			Err:   errors.New(errObj.Message),
+			Stack: errObj.Stack,
+		}
+	}
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+	return obj, nil
+}
+
+// canonical returns the boolean and null objects the engines compare
+// by identity for a value made by the host
+func canonical(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return object.NULL
+	case *object.Boolean:
+		if obj.Value {
+			return object.TRUE
+		}
+		return object.FALSE
+	}
+	return obj
+}
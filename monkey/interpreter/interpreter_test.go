@@ -0,0 +1,565 @@
+// Package interpreter interpreter/interpreter_test.go
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"monkey/object"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var engines = []string{EngineVM, EngineEval}
+
+func newInterpreter(t *testing.T, engine string) *Interpreter {
+	in, err := New(Options{Engine: engine, Context: &object.Context{}})
+	if err != nil {
+		t.Fatalf("New error: %s", err)
+	}
+	return in
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		inputs   []string
+		expected string
+	}{
+		{[]string{"1 + 2"}, "3"},
+		{[]string{"let x = 5;"}, "null"},
+		{[]string{""}, "null"},
+		{[]string{"let x = 5;", "x * 2"}, "10"},
+		{[]string{"let add = fn(a, b) { a + b };", "add(1, 2)"}, "3"},
+		{
+			[]string{
+				"let counter = fn() { let n = 0; fn() { n += 1; n } };",
+				"let next = counter(); next();",
+				"next()",
+			},
+			"2",
+		},
+		{
+			[]string{
+				"let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };",
+				"unless(1 > 2, \"yes\", \"no\")",
+			},
+			"yes",
+		},
+		{[]string{"let a = [1];", "push(a, 2)"}, "[1, 2]"},
+		{
+			[]string{`[{1: "a"}[1.0], {1.0: "b"}[1], {0.0: "z"}[-0.0], {1.5: "c"}[1]]`},
+			"[a, b, z, null]",
+		},
+		{
+			[]string{
+				"let m = macro(a) { quote(unquote(a) * 2) };",
+				"let x = m(3);",
+				"[x, m(10)]",
+			},
+			"[6, 20]",
+		},
+	}
+
+	for _, engine := range engines {
+		for _, tt := range tests {
+			in := newInterpreter(t, engine)
+			var result object.Object
+			for _, src := range tt.inputs {
+				var err error
+				result, err = in.Eval(src)
+				if err != nil {
+					t.Fatalf("%s: Eval(%q) error: %s", engine, src, err)
+				}
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: wrong result of %q. want=%s, got=%s",
+					engine, tt.inputs, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestLoopsInExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let q = 0; let s = 0; while (q < 5) { q += 1; let v = if (q == 2) { continue; } else { q }; s += v }; s", "13"},
+		{"let q = 0; let a = []; while (q < 3) { q += 1; a = push(a, [q, if (q == 2) { continue } else { q }]) }; a", "[[1, 1], [3, 3]]"},
+		{"let s = 0; for (x in [1, 2, 3]) { s = s + if (x == 2) { break } else { x } }; s", "1"},
+		{"let s = 0; for (x in [1, 2, 3]) { s += -if (x == 2) { continue } else { x } }; s", "-4"},
+		{"let h = {}; for (x in [1, 2, 3]) { h[x] = if (x == 2) { continue } else { x * 10 } }; [h[1], h[2], h[3]]", "[10, null, 30]"},
+		{"let f = fn() { let a = [1, if (true) { return 2 }]; 3 }; f()", "2"},
+	}
+
+	for _, engine := range engines {
+		for _, tt := range tests {
+			in := newInterpreter(t, engine)
+			result, err := in.Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: Eval(%q) error: %s", engine, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: wrong result of %q. want=%s, got=%s",
+					engine, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, engine := range engines {
+		in, err := New(Options{Engine: engine, File: "script.mk"})
+		if err != nil {
+			t.Fatalf("New error: %s", err)
+		}
+
+		_, err = in.Eval("let = 1;")
+		if _, ok := err.(*ParseError); !ok {
+			t.Errorf("%s: expected *ParseError, got %T (%v)", engine, err, err)
+		} else if !strings.HasPrefix(err.Error(), "script.mk:1:5: expected next token") {
+			t.Errorf("%s: wrong parse error. got=%q", engine, err)
+		}
+
+		_, err = in.Eval("let f = fn() { 1 / 0 };\nf();")
+		runtimeErr, ok := err.(*object.RuntimeError)
+		if !ok {
+			t.Fatalf("%s: expected *object.RuntimeError, got %T (%v)", engine, err, err)
+		}
+		if runtimeErr.Err.Error() != "division by zero" {
+			t.Errorf("%s: wrong runtime error. got=%q", engine, runtimeErr.Err)
+		}
+		if len(runtimeErr.Stack) != 2 || runtimeErr.Stack[0].Function != "f" ||
+			runtimeErr.Stack[0].Location.File != "script.mk" {
+			t.Errorf("%s: wrong stack. got=%+v", engine, runtimeErr.Stack)
+		}
+
+		// the interpreter can still be used after an error
+		result, err := in.Eval("f; 7")
+		if err != nil || result.Inspect() != "7" {
+			t.Errorf("%s: wrong result after an error. got=%v (%v)", engine, result, err)
+		}
+	}
+
+	_, err := New(Options{Engine: "jit"})
+	if err == nil || !strings.Contains(err.Error(), `unknown engine "jit"`) {
+		t.Errorf("expected unknown engine error, got %v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	for _, engine := range engines {
+		in := newInterpreter(t, engine)
+		_, err := in.Eval(`
+		let add = fn(a, b) { a + b };
+		let negate = fn(b) { !b };
+		let total = 0;
+		let count = fn(n) { total += n; total };
+		let length = len;
+		let answer = 42;
+		`)
+		if err != nil {
+			t.Fatalf("%s: Eval error: %s", engine, err)
+		}
+
+		tests := []struct {
+			name     string
+			args     []object.Object
+			expected string
+		}{
+			{"add", []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}, "3"},
+			{"add", []object.Object{&object.String{Value: "a"}, &object.String{Value: "b"}}, "ab"},
+			{"negate", []object.Object{&object.Boolean{Value: false}}, "true"},
+			{"count", []object.Object{&object.Integer{Value: 5}}, "5"},
+			{"count", []object.Object{&object.Integer{Value: 5}}, "10"},
+			{"length", []object.Object{&object.String{Value: "four"}}, "4"},
+		}
+		for _, tt := range tests {
+			result, err := in.Call(tt.name, tt.args...)
+			if err != nil {
+				t.Fatalf("%s: Call(%s) error: %s", engine, tt.name, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: wrong result of %s. want=%s, got=%s",
+					engine, tt.name, tt.expected, result.Inspect())
+			}
+		}
+
+		errorTests := []struct {
+			name     string
+			args     []object.Object
+			expected string
+		}{
+			{"missing", nil, "undefined function missing"},
+			{"answer", nil, "answer is not a function: INTEGER"},
+			{"add", []object.Object{&object.Integer{Value: 1}}, "wrong number of arguments: want=2, got=1"},
+			{"add", []object.Object{&object.Integer{Value: 1}, &object.String{Value: "a"}}, "INTEGER"},
+		}
+		for _, tt := range errorTests {
+			_, err := in.Call(tt.name, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("%s: wrong error of %s. want=%q, got=%v",
+					engine, tt.name, tt.expected, err)
+			}
+		}
+
+		// globals changed by calls persist
+		result, err := in.Eval("total")
+		if err != nil || result.Inspect() != "10" {
+			t.Errorf("%s: wrong total after calls. got=%v (%v)", engine, result, err)
+		}
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	for _, engine := range engines {
+		in := newInterpreter(t, engine)
+
+		if _, ok := in.GetGlobal("x"); ok {
+			t.Errorf("%s: x defined before it is set", engine)
+		}
+		if _, ok := in.GetGlobal("len"); ok {
+			t.Errorf("%s: builtin len returned as a global", engine)
+		}
+
+		in.SetGlobal("x", &object.Integer{Value: 20})
+		in.SetGlobal("flag", &object.Boolean{Value: true})
+		result, err := in.Eval("let y = x + 1; if (flag == true) { y } else { 0 }")
+		if err != nil {
+			t.Fatalf("%s: Eval error: %s", engine, err)
+		}
+		if result.Inspect() != "21" {
+			t.Errorf("%s: wrong result. want=21, got=%s", engine, result.Inspect())
+		}
+
+		y, ok := in.GetGlobal("y")
+		if !ok || y.Inspect() != "21" {
+			t.Errorf("%s: wrong y. got=%v", engine, y)
+		}
+
+		in.SetGlobal("y", &object.String{Value: "changed"})
+		result, err = in.Eval("y")
+		if err != nil || result.Inspect() != "changed" {
+			t.Errorf("%s: SetGlobal did not rebind y. got=%v (%v)", engine, result, err)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	for _, engine := range engines {
+		var out bytes.Buffer
+		in, err := New(Options{Engine: engine, Context: &object.Context{Stdout: &out}})
+		if err != nil {
+			t.Fatalf("New error: %s", err)
+		}
+		_, err = in.Eval(`let greet = fn(name) { puts("hello " + name) };`)
+		if err != nil {
+			t.Fatalf("%s: Eval error: %s", engine, err)
+		}
+		_, err = in.Call("greet", &object.String{Value: "host"})
+		if err != nil {
+			t.Fatalf("%s: Call error: %s", engine, err)
+		}
+		if out.String() != "hello host\n" {
+			t.Errorf("%s: wrong output. got=%q", engine, out.String())
+		}
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	for _, engine := range engines {
+		in := newInterpreter(t, engine)
+		other := newInterpreter(t, engine)
+
+		var calls []string
+		err := in.RegisterFunc("record", func(args ...object.Object) object.Object {
+			calls = append(calls, args[0].Inspect())
+			return &object.Integer{Value: int64(len(calls))}
+		})
+		if err != nil {
+			t.Fatalf("%s: RegisterFunc error: %s", engine, err)
+		}
+		err = in.RegisterBuiltin("len", &object.Builtin{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				return &object.String{Value: "replaced"}
+			},
+		})
+		if err != nil {
+			t.Fatalf("%s: RegisterBuiltin error: %s", engine, err)
+		}
+
+		result, err := in.Eval(`record("a"); record("b")`)
+		if err != nil {
+			t.Fatalf("%s: Eval error: %s", engine, err)
+		}
+		if result.Inspect() != "2" || len(calls) != 2 || calls[1] != "b" {
+			t.Errorf("%s: wrong calls. got=%s, %q", engine, result.Inspect(), calls)
+		}
+		result, err = in.Eval(`len([])`)
+		if err != nil || result.Inspect() != "replaced" {
+			t.Errorf("%s: len not replaced. got=%v (%v)", engine, result, err)
+		}
+
+		// other interpreters do not see the builtins
+		_, err = other.Eval(`record("c")`)
+		if err == nil {
+			t.Errorf("%s: record callable in another interpreter", engine)
+		}
+		result, err = other.Eval(`len([])`)
+		if err != nil || result.Inspect() != "0" {
+			t.Errorf("%s: len replaced in another interpreter. got=%v (%v)",
+				engine, result, err)
+		}
+
+		// a global hides a builtin registered after it
+		_, err = in.Eval(`let shadow = 1;`)
+		if err != nil {
+			t.Fatalf("%s: Eval error: %s", engine, err)
+		}
+		in.RegisterFunc("shadow", func(args ...object.Object) object.Object { return nil })
+		result, err = in.Eval(`shadow`)
+		if err != nil || result.Inspect() != "1" {
+			t.Errorf("%s: builtin not hidden by global. got=%v (%v)", engine, result, err)
+		}
+	}
+}
+
+func TestGoValues(t *testing.T) {
+	type item struct {
+		Name  string `json:"name"`
+		Price int    `json:"price"`
+		Sold  bool   `json:"sold"`
+	}
+
+	for _, engine := range engines {
+		in := newInterpreter(t, engine)
+
+		items, err := object.FromGo([]item{{"apple", 3, false}, {"pear", 5, true}})
+		if err != nil {
+			t.Fatalf("FromGo error: %s", err)
+		}
+		in.SetGlobal("items", items)
+		discount, err := object.FromGo(func(price int, percent float64) float64 {
+			return float64(price) * (1 - percent/100)
+		})
+		if err != nil {
+			t.Fatalf("FromGo error: %s", err)
+		}
+		in.RegisterBuiltin("discount", discount.(*object.Builtin))
+
+		result, err := in.Eval(`
+		let total = 0.0;
+		for (item in items) {
+			if (!item["sold"]) { total += discount(item["price"], 50) }
+		}
+		{"total": total, "first": items[0]["name"]}
+		`)
+		if err != nil {
+			t.Fatalf("%s: Eval error: %s", engine, err)
+		}
+		value, err := object.ToGo(result)
+		if err != nil {
+			t.Fatalf("%s: ToGo error: %s", engine, err)
+		}
+		expected := map[string]interface{}{"total": 1.5, "first": "apple"}
+		if !reflect.DeepEqual(value, expected) {
+			t.Errorf("%s: wrong result. want=%v, got=%v", engine, expected, value)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for _, engine := range engines {
+		in, err := New(Options{
+			Engine: engine,
+			File:   "loop.mk",
+			Limits: object.Limits{MaxInstructions: 10000},
+		})
+		if err != nil {
+			t.Fatalf("New error: %s", err)
+		}
+
+		_, err = in.Eval("let spin = fn() { while (true) { } };\nspin()")
+		abortErr, ok := err.(*object.AbortError)
+		if !ok || !errors.Is(err, object.ErrInstructionLimit) {
+			t.Fatalf("%s: expected instruction limit, got %T (%v)", engine, err, err)
+		}
+		if abortErr.Stack[0].Function != "spin" || abortErr.Stack[0].Location.File != "loop.mk" {
+			t.Errorf("%s: wrong stack. got=%+v", engine, abortErr.Stack)
+		}
+
+		// the limit applies to each run
+		for i := 0; i < 3; i++ {
+			result, err := in.Eval("let i = 0; while (i < 100) { i += 1 }; i")
+			if err != nil || result.Inspect() != "100" {
+				t.Fatalf("%s: wrong result. got=%v (%v)", engine, result, err)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err = in.CallContext(ctx, "spin")
+		cancel()
+		if _, ok := err.(*object.AbortError); !ok {
+			t.Errorf("%s: expected *object.AbortError, got %T (%v)", engine, err, err)
+		}
+
+		// script errors are not abort errors
+		_, err = in.Eval("1 / 0")
+		if _, ok := err.(*object.RuntimeError); !ok {
+			t.Errorf("%s: expected *object.RuntimeError, got %T (%v)", engine, err, err)
+		}
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	for _, engine := range engines {
+		in, err := New(Options{
+			Engine: engine,
+			Limits: object.Limits{MaxMemory: 1 << 16},
+		})
+		if err != nil {
+			t.Fatalf("New error: %s", err)
+		}
+		in.RegisterFunc("grow", func(args ...object.Object) object.Object {
+			return &object.String{Value: strings.Repeat("x", 1<<17)}
+		})
+
+		_, err = in.Eval("let a = []; while (true) { a = push(a, a) }")
+		if _, ok := err.(*object.AbortError); !ok || !errors.Is(err, object.ErrMemoryLimit) {
+			t.Errorf("%s: expected memory limit, got %T (%v)", engine, err, err)
+		}
+		_, err = in.Eval("grow()")
+		if !errors.Is(err, object.ErrMemoryLimit) {
+			t.Errorf("%s: expected memory limit for a builtin result, got %v", engine, err)
+		}
+
+		// literals are not counted, in either engine
+		result, err := in.Eval(`let i = 0; while (i < 10000) { "literal"; i += 1 }; i`)
+		if err != nil || result.Inspect() != "10000" {
+			t.Errorf("%s: wrong result. got=%v (%v)", engine, result, err)
+		}
+
+		// the quota applies to each run
+		result, err = in.Eval("len([1, 2, 3])")
+		if err != nil || result.Inspect() != "3" {
+			t.Errorf("%s: wrong result. got=%v (%v)", engine, result, err)
+		}
+	}
+}
+
+func TestRecursionDepth(t *testing.T) {
+	for _, engine := range engines {
+		in, err := New(Options{Engine: engine, Limits: object.Limits{MaxDepth: 50}})
+		if err != nil {
+			t.Fatalf("New error: %s", err)
+		}
+		_, err = in.Eval("let down = fn(n) { if (n == 0) { 0 } else { 1 + down(n - 1) } }")
+		if err != nil {
+			t.Fatalf("%s: Eval error: %s", engine, err)
+		}
+
+		result, err := in.Call("down", &object.Integer{Value: 49})
+		if err != nil || result.Inspect() != "49" {
+			t.Errorf("%s: wrong result. got=%v (%v)", engine, result, err)
+		}
+		_, err = in.Call("down", &object.Integer{Value: 50})
+		runtimeErr, ok := err.(*object.RuntimeError)
+		if !ok || runtimeErr.Err.Error() != "maximum recursion depth exceeded in down" {
+			t.Fatalf("%s: wrong error. got=%T (%v)", engine, err, err)
+		}
+		calls := 0
+		for _, frame := range runtimeErr.Stack {
+			if frame.Function == "down" {
+				calls++
+			}
+		}
+		if calls != 50 {
+			t.Errorf("%s: wrong stack depth. want=50, got=%d", engine, calls)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tracebacks := map[string]string{}
+	for _, engine := range engines {
+		in, err := New(Options{Engine: engine, Limits: object.Limits{MaxDepth: 10}})
+		if err != nil {
+			t.Fatalf("New error: %s", err)
+		}
+		result, err := in.Eval("let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } };\n" +
+			"count(100000, 0)")
+		if err != nil || result.Inspect() != "100000" {
+			t.Errorf("%s: wrong result. got=%v (%v)", engine, result, err)
+		}
+
+		_, err = in.Eval("let inner = fn(x) { x + true };\nlet outer = fn(y) { inner(y) };\nouter(1)")
+		runtimeErr, ok := err.(*object.RuntimeError)
+		if !ok {
+			t.Fatalf("%s: expected *object.RuntimeError, got %T (%v)", engine, err, err)
+		}
+		tracebacks[engine] = runtimeErr.Traceback()
+	}
+
+	// inner runs in the frame of outer in both engines
+	expected := `Traceback (most recent call last):
+  line 3, column 6, in <main>
+  line 1, column 23, in inner
+`
+	for engine, traceback := range tracebacks {
+		if !strings.HasPrefix(traceback, expected) {
+			t.Errorf("%s: wrong traceback, expected=\n%s\ngot=\n%s", engine, expected, traceback)
+		}
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	plain := newInterpreter(t, EngineVM)
+	optimized, err := New(Options{Engine: EngineVM, Optimize: true})
+	if err != nil {
+		t.Fatalf("New error: %s", err)
+	}
+
+	tests := []string{
+		"let x = 2 * 3 + 1; x * 1",
+		"if (1 < 2) { \"a\" + \"b\" } else { 0 }",
+		"let f = fn(n) { n * (4 - 3) }; f(5)",
+	}
+	for _, input := range tests {
+		expected, err := plain.Eval(input)
+		if err != nil {
+			t.Fatalf("plain error: %s", err)
+		}
+		result, err := optimized.Eval(input)
+		if err != nil {
+			t.Fatalf("optimized error: %s", err)
+		}
+		if result.Inspect() != expected.Inspect() {
+			t.Errorf("%q: wrong result. expected=%s, got=%s", input, expected.Inspect(), result.Inspect())
+		}
+	}
+
+	// folding keeps the positions of failing operations
+	input := "let f = fn() { (2 + 1) / (1 - 1) };\nf()"
+	_, expected := plain.Eval(input)
+	_, err = optimized.Eval(input)
+	if expected == nil || err == nil || err.Error() != expected.Error() {
+		t.Errorf("wrong error. expected=%v, got=%v", expected, err)
+	}
+}
+
+func TestEvalContextCanceled(t *testing.T) {
+	for _, engine := range engines {
+		in := newInterpreter(t, engine)
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+		_, err := in.EvalContext(ctx, "while (true) { }")
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", engine, err)
+		}
+	}
+}
@@ -11,16 +11,33 @@ type Lexer struct {
 	position     int
 	readPosition int
 	ch           byte
+	// line and column of ch, both 1-based
+	line   int
+	column int
+	// return comments as COMMENT tokens instead of skipping them
+	emitComments bool
 }
 
 // New Creates a new Lexer
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// NewWithComments Creates a new Lexer that returns
+// comments as COMMENT tokens, for use by tools
+func NewWithComments(input string) *Lexer {
+	l := New(input)
+	l.emitComments = true
+	return l
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -28,6 +45,7 @@ func (l *Lexer) readChar() {
 	}
 	l.position = l.readPosition
 	l.readPosition++
+	l.column++
 }
 
 func (l *Lexer) peekChar() byte {
@@ -40,46 +58,46 @@ func (l *Lexer) peekChar() byte {
 // NextToken returns the next token
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
-	tok.Col = l.position
 	l.skipWhitespace()
+	for l.atComment() {
+		tok.Line, tok.Column, tok.Offset = l.line, l.column, l.position
+		literal, terminated := l.readComment()
+		if !terminated {
+			tok.Type = token.ILLEGAL
+			tok.Literal = literal
+			return tok
+		}
+		if l.emitComments {
+			tok.Type = token.COMMENT
+			tok.Literal = literal
+			return tok
+		}
+		l.skipWhitespace()
+	}
+	line, column, offset := l.line, l.column, l.position
 
 	switch l.ch {
-	case '+':
-		tok = newToken(token.PLUS, l.ch, l.position)
-	case '-':
-		tok = newToken(token.MINUS, l.ch, l.position)
-	case '/':
-		tok = newToken(token.SLASH, l.ch, l.position)
-	case '*':
-		tok = newToken(token.ASTERISK, l.ch, l.position)
-
-	case '=':
-		tok = l.newTwoByteToken()
-	case '!':
+	case '=', '!', '<', '>', '&', '|', '+', '-', '/', '*', '%':
 		tok = l.newTwoByteToken()
 
 	case ',':
-		tok = newToken(token.COMMA, l.ch, l.position)
+		tok = newToken(token.COMMA, l.ch)
 	case ':':
-		tok = newToken(token.COLON, l.ch, l.position)
+		tok = newToken(token.COLON, l.ch)
 	case ';':
-		tok = newToken(token.SEMICOLON, l.ch, l.position)
+		tok = newToken(token.SEMICOLON, l.ch)
 	case '(':
-		tok = newToken(token.LPAREN, l.ch, l.position)
+		tok = newToken(token.LPAREN, l.ch)
 	case ')':
-		tok = newToken(token.RPAREN, l.ch, l.position)
+		tok = newToken(token.RPAREN, l.ch)
 	case '{':
-		tok = newToken(token.LBRACE, l.ch, l.position)
+		tok = newToken(token.LBRACE, l.ch)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch, l.position)
+		tok = newToken(token.RBRACE, l.ch)
 	case '[':
-		tok = newToken(token.LBRACKET, l.ch, l.position)
+		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
-		tok = newToken(token.RBRACKET, l.ch, l.position)
-	case '<':
-		tok = newToken(token.LT, l.ch, l.position)
-	case '>':
-		tok = newToken(token.GT, l.ch, l.position)
+		tok = newToken(token.RBRACKET, l.ch)
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
@@ -90,18 +108,18 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
-			tok.Col = l.position
+			tok.Line, tok.Column, tok.Offset = line, column, offset
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
-			tok.Col = l.position
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Line, tok.Column, tok.Offset = line, column, offset
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch, l.position)
+			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 	l.readChar()
+	tok.Line, tok.Column, tok.Offset = line, column, offset
 	return tok
 }
 
@@ -114,12 +132,66 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func (l *Lexer) readNumber() string {
+func (l *Lexer) atComment() bool {
+	return l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*')
+}
+
+// readComment reads a // line comment up to the end of the line,
+// or a /* */ block comment including its delimiters. It reports
+// false if a block comment is not terminated.
+func (l *Lexer) readComment() (string, bool) {
 	position := l.position
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return l.input[position:l.position], true
+	}
+	l.readChar()
+	l.readChar()
+	for !(l.ch == '*' && l.peekChar() == '/') {
+		if l.ch == 0 {
+			return l.input[position:l.position], false
+		}
+		l.readChar()
+	}
+	l.readChar()
+	l.readChar()
+	return l.input[position:l.position], true
+}
+
+// readNumber reads an integer, or a float with a fraction
+// and/or an exponent such as 1.5, 2e10 or 1.5e-3
+func (l *Lexer) readNumber() (token.TokenType, string) {
+	var tokenType token.TokenType = token.INT
+	position := l.position
+	l.readDigits()
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if (next == '+' || next == '-') && l.readPosition+1 < len(l.input) {
+			next = l.input[l.readPosition+1]
+		}
+		if isDigit(next) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+	return tokenType, l.input[position:l.position]
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
 func isDigit(ch byte) bool {
@@ -153,27 +225,46 @@ func isLetter(ch byte) bool {
 		ch == '_'
 }
 
+var twoByteTokens = map[string]token.TokenType{
+	"==": token.EQ,
+	"!=": token.NOT_EQ,
+	"<=": token.LT_EQ,
+	">=": token.GT_EQ,
+	"&&": token.AND,
+	"||": token.OR,
+	"+=": token.PLUS_ASSIGN,
+	"-=": token.MINUS_ASSIGN,
+	"*=": token.ASTERISK_ASSIGN,
+	"/=": token.SLASH_ASSIGN,
+	"%=": token.PERCENT_ASSIGN,
+	"=>": token.ARROW,
+}
+
+var oneByteTokens = map[byte]token.TokenType{
+	'=': token.ASSIGN,
+	'!': token.BANG,
+	'<': token.LT,
+	'>': token.GT,
+	'+': token.PLUS,
+	'-': token.MINUS,
+	'/': token.SLASH,
+	'*': token.ASTERISK,
+	'%': token.PERCENT,
+}
+
 func (l *Lexer) newTwoByteToken() token.Token {
-	var tokenType token.TokenType = token.ILLEGAL
 	ch := l.ch
-	if l.peekChar() == '=' {
-		if ch == '!' {
-			tokenType = token.NOT_EQ
-		} else if ch == '=' {
-			tokenType = token.EQ
-		}
+	literal := string(ch) + string(l.peekChar())
+	if tokenType, ok := twoByteTokens[literal]; ok {
 		l.readChar()
-		literal := string(ch) + string(l.ch)
 		return token.Token{Type: tokenType, Literal: literal}
 	}
-	if ch == '!' {
-		tokenType = token.BANG
-	} else if ch == '=' {
-		tokenType = token.ASSIGN
+	if tokenType, ok := oneByteTokens[ch]; ok {
+		return newToken(tokenType, ch)
 	}
-	return newToken(tokenType, l.ch, l.position)
+	return newToken(token.ILLEGAL, ch)
 }
 
-func newToken(tokenType token.TokenType, ch byte, col int) token.Token {
-	return token.Token{Type: tokenType, Literal: string(ch), Col: col}
+func newToken(tokenType token.TokenType, ch byte) token.Token {
+	return token.Token{Type: tokenType, Literal: string(ch)}
 }
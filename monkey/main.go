@@ -3,21 +3,57 @@ package main
 
 import (
 	"fmt"
-	"monkey/repl"
+	"io"
 	"os"
-	"os/user"
 )
 
-func main() {
-	user, err := user.Current()
-	if err != nil {
-		panic(err)
-	}
+// exit codes of the monkey command
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const usage = `Usage: monkey <command> [arguments]
+
+Commands:
+  run [--engine=vm|eval] <file>   run a .mk script or a .mbc bytecode file
+  build [-o <output>] <file>      compile a script into a .mbc bytecode file
+  dis <file>                      disassemble a script or a .mbc bytecode file
+  ast <file>                      print the syntax tree of a script
+  repl                            start the interactive interpreter
 
-	fmt.Printf("Hello %s! This is the Monkey "+
-		"programming language!\n", user.Username)
+Without a command monkey starts the interactive interpreter.
+`
 
-	fmt.Printf("Feel free to type in commands\n")
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-	repl.Start(os.Stdin, os.Stdout)
+// run executes the command line args and returns the exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		return runRepl(nil, stdin, stdout, stderr)
+	}
+
+	var command func([]string, io.Reader, io.Writer, io.Writer) int
+	switch args[0] {
+	case "run":
+		command = runScript
+	case "build":
+		command = runBuild
+	case "dis":
+		command = runDis
+	case "ast":
+		command = runAst
+	case "repl":
+		command = runRepl
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "monkey: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+	return command(args[1:], stdin, stdout, stderr)
 }
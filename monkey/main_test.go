@@ -0,0 +1,235 @@
+// Package main main_test.go
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testScript = `let add = fn(a, b) { a + b };
+let result = add(1, 2);
+`
+
+func TestCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeScript := func(name, src string) string {
+		filename := filepath.Join(dir, name)
+		err := ioutil.WriteFile(filename, []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	script := writeScript("add.mk", testScript)
+	failing := writeScript("fail.mk", "let f = fn() { 1 / 0 };\nf();\n")
+	invalid := writeScript("invalid.mk", "let = 5;")
+	undefined := writeScript("undefined.mk", "x + 1;")
+	loop := writeScript("loop.mk", "while (true) { }")
+	recurse := writeScript("recurse.mk", "let f = fn() { 1 + f() };\nf()")
+	grow := writeScript("grow.mk", "let a = []; while (true) { a = push(a, 1) }")
+	constant := writeScript("constant.mk", "puts(2 * 3 + 1)")
+	bytecode := filepath.Join(dir, "out.mbc")
+
+	tests := []struct {
+		args           []string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"run", script}, exitOK, "", ""},
+		{[]string{"run", "--engine=eval", script}, exitOK, "", ""},
+		{[]string{"run", script, "--engine=eval"}, exitOK, "", ""},
+		{
+			[]string{"run", failing},
+			exitError,
+			"",
+			"line 2, column 2, in <main>",
+		},
+		{
+			[]string{"run", "--engine=eval", failing},
+			exitError,
+			"",
+			"line 1, column 18, in f\ndivision by zero",
+		},
+		{[]string{"run", invalid}, exitError, "", invalid + ":1:5: expected next token"},
+		{[]string{"run", undefined}, exitError, "", undefined + ":1:1: undefined variable x"},
+		{[]string{"run", filepath.Join(dir, "missing.mk")}, exitError, "", "missing.mk"},
+		{[]string{"run"}, exitUsage, "", "expected one file, got 0"},
+		{[]string{"run", script, script}, exitUsage, "", "expected one file, got 2"},
+		{[]string{"run", "--engine=jit", script}, exitUsage, "", `unknown engine "jit"`},
+		{
+			[]string{"run", "--max-instructions=100", loop},
+			exitError,
+			"",
+			"in <main>\ninstruction limit exceeded",
+		},
+		{
+			[]string{"run", "--engine=eval", "--timeout=10ms", loop},
+			exitError,
+			"",
+			"in <main>\nexecution timed out",
+		},
+		{
+			[]string{"run", "--max-memory=65536", grow},
+			exitError,
+			"",
+			"in <main>\nmemory limit exceeded",
+		},
+		{
+			[]string{"run", "--engine=eval", "--max-memory=65536", grow},
+			exitError,
+			"",
+			"in <main>\nmemory limit exceeded",
+		},
+		{
+			[]string{"run", "--max-depth=5", recurse},
+			exitError,
+			"",
+			"[previous frame repeated 2 more times]\nmaximum recursion depth exceeded in f",
+		},
+		{
+			[]string{"run", "--engine=eval", recurse},
+			exitError,
+			"",
+			"[previous frame repeated 9997 more times]\nmaximum recursion depth exceeded in f",
+		},
+		{[]string{"run", "--timeout=soon", loop}, exitUsage, "", "invalid value"},
+		{[]string{"build", script, "-o", bytecode}, exitOK, "", ""},
+		{[]string{"run", bytecode}, exitOK, "", ""},
+		{[]string{"run", "--engine=eval", bytecode}, exitUsage, "", "cannot run bytecode"},
+		{[]string{"build", invalid}, exitError, "", "expected next token"},
+		{[]string{"dis", bytecode}, exitOK, "<main>:\n0000 OpClosure 0 0\n", ""},
+		{
+			[]string{"dis", script},
+			exitOK,
+			"constant 0, add (parameters=2, locals=2):\n" +
+				"0000 OpGetLocal 0\n" +
+				"0002 OpGetLocal 1\n" +
+				"0004 OpAdd\n" +
+				"0005 OpReturnValue\n",
+			"",
+		},
+		{
+			[]string{"dis", "--optimize", constant},
+			exitOK,
+			"<main>:\n" +
+				"0000 OpGetBuiltin 1\n" +
+				"0002 OpConstant 0\n" +
+				"0005 OpCall 1\n" +
+				"0007 OpPop\n" +
+				"\nconstants:\n" +
+				"0000 INTEGER 7\n",
+			"",
+		},
+		{[]string{"run", "--optimize", constant}, exitOK, "7\n", ""},
+		{
+			[]string{"ast", script},
+			exitOK,
+			"  LetStatement 1:1\n" +
+				"    Identifier 1:5 add\n" +
+				"    FunctionLiteral 1:11 add\n",
+			"",
+		},
+		{[]string{"ast", bytecode}, exitUsage, "", "is bytecode"},
+		{[]string{"repl", "extra"}, exitUsage, "", `unexpected argument "extra"`},
+		{[]string{"compile"}, exitUsage, "", `unknown command "compile"`},
+		{[]string{"help"}, exitOK, "Usage: monkey <command>", ""},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(""), &stdout, &stderr)
+		if code != tt.expectedCode {
+			t.Errorf("monkey %s: wrong exit code. want=%d, got=%d (stderr=%q)",
+				strings.Join(tt.args, " "), tt.expectedCode, code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), tt.expectedStdout) {
+			t.Errorf("monkey %s: stdout does not contain %q. got=%q",
+				strings.Join(tt.args, " "), tt.expectedStdout, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), tt.expectedStderr) {
+			t.Errorf("monkey %s: stderr does not contain %q. got=%q",
+				strings.Join(tt.args, " "), tt.expectedStderr, stderr.String())
+		}
+		if tt.expectedStderr == "" && stderr.Len() != 0 {
+			t.Errorf("monkey %s: unexpected stderr %q",
+				strings.Join(tt.args, " "), stderr.String())
+		}
+	}
+}
+
+func TestBuildDefaultOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "add.mk")
+	err = ioutil.WriteFile(script, []byte(testScript), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"build", script}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("wrong exit code. want=%d, got=%d (stderr=%q)",
+			exitOK, code, stderr.String())
+	}
+	_, err = os.Stat(filepath.Join(dir, "add.mbc"))
+	if err != nil {
+		t.Errorf("bytecode file not written: %s", err)
+	}
+}
+
+func TestReplCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"repl"}, strings.NewReader("1 + 2\n"), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("wrong exit code. want=%d, got=%d", exitOK, code)
+	}
+	if !strings.Contains(stdout.String(), ">> 3\n") {
+		t.Errorf("repl output does not contain the result. got=%q", stdout.String())
+	}
+}
+
+func TestRunStreams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "echo.mk")
+	src := "let name = gets();\nputs(\"hello \" + name);\neputs(\"bye \" + name);\n"
+	err = ioutil.WriteFile(script, []byte(src), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, engine := range []string{"vm", "eval"} {
+		var stdout, stderr bytes.Buffer
+		args := []string{"run", "--engine=" + engine, script}
+		code := run(args, strings.NewReader("monkey\n"), &stdout, &stderr)
+		if code != exitOK {
+			t.Fatalf("%s: wrong exit code. want=%d, got=%d (stderr=%q)",
+				engine, exitOK, code, stderr.String())
+		}
+		if stdout.String() != "hello monkey\n" {
+			t.Errorf("%s: wrong stdout. got=%q", engine, stdout.String())
+		}
+		if stderr.String() != "bye monkey\n" {
+			t.Errorf("%s: wrong stderr. got=%q", engine, stderr.String())
+		}
+	}
+}
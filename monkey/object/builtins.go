@@ -3,6 +3,7 @@ package object
 
 import (
 	"fmt"
+	"io"
 )
 
 var Builtins = []struct {
@@ -12,7 +13,7 @@ var Builtins = []struct {
 	{
 		"len",
 		&Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx *Context, args ...Object) Object {
 				if len(args) != 1 {
 					return newError("wrong number of arguments, "+
 						"got=%d, want=1", len(args))
@@ -36,9 +37,10 @@ var Builtins = []struct {
 	{
 		"puts",
 		&Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx *Context, args ...Object) Object {
+				out := ctx.stdout()
 				for _, arg := range args {
-					fmt.Println(arg.Inspect())
+					fmt.Fprintln(out, arg.Inspect())
 				}
 				return nil
 			},
@@ -47,7 +49,7 @@ var Builtins = []struct {
 	{
 		"first",
 		&Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx *Context, args ...Object) Object {
 				if len(args) != 1 {
 					return newError("wrong number of arguments, "+
 						"got=%d, want=1", len(args))
@@ -67,7 +69,7 @@ var Builtins = []struct {
 	{
 		"last",
 		&Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx *Context, args ...Object) Object {
 				if len(args) != 1 {
 					return newError("wrong number of arguments, "+
 						"got=%d, want=1", len(args))
@@ -88,7 +90,7 @@ var Builtins = []struct {
 	{
 		"rest",
 		&Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx *Context, args ...Object) Object {
 				if len(args) != 1 {
 					return newError("wrong number of arguments, "+
 						"got=%d, want=1", len(args))
@@ -111,7 +113,7 @@ var Builtins = []struct {
 	{
 		"push",
 		&Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx *Context, args ...Object) Object {
 				if len(args) != 2 {
 					return newError("wrong number of arguments, "+
 						"got=%d, want=2", len(args))
@@ -129,6 +131,37 @@ var Builtins = []struct {
 			},
 		},
 	},
+	{
+		"eputs",
+		&Builtin{
+			Fn: func(ctx *Context, args ...Object) Object {
+				out := ctx.stderr()
+				for _, arg := range args {
+					fmt.Fprintln(out, arg.Inspect())
+				}
+				return nil
+			},
+		},
+	},
+	{
+		"gets",
+		&Builtin{
+			Fn: func(ctx *Context, args ...Object) Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments, "+
+						"got=%d, want=0", len(args))
+				}
+				line, err := ctx.ReadLine()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return newError("gets: %s", err)
+				}
+				return &String{Value: line}
+			},
+		},
+	},
 }
 
 func newError(format string, a ...interface{}) *Error {
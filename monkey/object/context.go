@@ -0,0 +1,63 @@
+// Package object object/context.go
+package object
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Context is the execution context of builtins: the streams that
+// puts and the other I/O builtins write to and read from. A nil
+// writer discards the output and a nil reader is empty.
+type Context struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// stdin buffers Stdin, which it was created for, for ReadLine
+	stdin       *bufio.Reader
+	stdinSource io.Reader
+}
+
+// NewContext returns a context for the standard streams of the process
+func NewContext() *Context {
+	return &Context{Stdout: os.Stdout, Stderr: os.Stderr, Stdin: os.Stdin}
+}
+
+// StandardContext is used when no other context is set
+var StandardContext = NewContext()
+
+func (c *Context) stdout() io.Writer {
+	if c.Stdout == nil {
+		return ioutil.Discard
+	}
+	return c.Stdout
+}
+
+func (c *Context) stderr() io.Writer {
+	if c.Stderr == nil {
+		return ioutil.Discard
+	}
+	return c.Stderr
+}
+
+// ReadLine reads the next line from Stdin, without its line ending
+func (c *Context) ReadLine() (string, error) {
+	if c.Stdin == nil {
+		return "", io.EOF
+	}
+	if c.stdin == nil || c.stdinSource != c.Stdin {
+		c.stdin = bufio.NewReader(c.Stdin)
+		c.stdinSource = c.Stdin
+	}
+
+	line, err := c.stdin.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), err
+}
@@ -0,0 +1,85 @@
+// Package object object/context_test.go
+package object
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestContextReadLine(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"one\ntwo\n", []string{"one", "two"}},
+		{"one\r\ntwo", []string{"one", "two"}},
+		{"\n\n", []string{"", ""}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		ctx := &Context{Stdin: strings.NewReader(tt.input)}
+		lines := []string{}
+		for {
+			line, err := ctx.ReadLine()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("ReadLine error for %q: %s", tt.input, err)
+			}
+			lines = append(lines, line)
+		}
+		if !reflect.DeepEqual(lines, tt.expected) {
+			t.Errorf("wrong lines for %q. want=%q, got=%q", tt.input, tt.expected, lines)
+		}
+	}
+}
+
+func TestContextStdinReplaced(t *testing.T) {
+	ctx := &Context{}
+	if _, err := ctx.ReadLine(); err != io.EOF {
+		t.Errorf("expected io.EOF without Stdin, got %v", err)
+	}
+
+	ctx.Stdin = strings.NewReader("one\nrest\n")
+	ctx.ReadLine()
+	ctx.Stdin = strings.NewReader("two\n")
+	line, err := ctx.ReadLine()
+	if err != nil || line != "two" {
+		t.Errorf("wrong line from the new Stdin. got=%q (%v)", line, err)
+	}
+}
+
+func TestIOBuiltins(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	ctx := &Context{
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Stdin:  strings.NewReader("line\n"),
+	}
+
+	GetBuiltinByName("puts").Fn(ctx, &String{Value: "out"}, &Integer{Value: 1})
+	GetBuiltinByName("eputs").Fn(ctx, &String{Value: "err"})
+	line := GetBuiltinByName("gets").Fn(ctx)
+	end := GetBuiltinByName("gets").Fn(ctx)
+
+	if stdout.String() != "out\n1\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+	if stderr.String() != "err\n" {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+	if s, ok := line.(*String); !ok || s.Value != "line" {
+		t.Errorf("gets returned %+v, want the line", line)
+	}
+	if end != nil {
+		t.Errorf("gets returned %+v at the end of the input, want nil", end)
+	}
+
+	// a context without streams discards output
+	GetBuiltinByName("puts").Fn(&Context{}, &String{Value: "lost"})
+}
@@ -0,0 +1,462 @@
+// Package object object/convert.go
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+var (
+	objectType = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// FromGo converts a Go value to an object. Booleans, integers, floats
+// and strings become Boolean, Integer, Float and String, nil becomes
+// NULL, slices and arrays become Array and maps with string, integer
+// or boolean keys become Hash. Pointers and interfaces convert to the
+// value they point to. Structs convert through JSON, so their json
+// tags name the keys of the Hash. A func becomes a Builtin that
+// converts its arguments to the parameter types and its result back;
+// a last result of type error that is not nil becomes an Error. An
+// Object is returned as it is.
+func FromGo(v interface{}) (Object, error) {
+	return fromGo(reflect.ValueOf(v))
+}
+
+func fromGo(v reflect.Value) (Object, error) {
+	if !v.IsValid() {
+		return NULL, nil
+	}
+	if v.Type().Implements(objectType) && v.CanInterface() {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return NULL, nil
+		}
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return nativeBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows %s", v.Uint(), INTEGER_OBJ)
+		}
+		return &Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return NULL, nil
+		}
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			element, err := fromGo(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return fromGoMap(v)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return fromGo(v.Elem())
+	case reflect.Struct:
+		return fromJSON(v)
+	case reflect.Func:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return fromGoFunc(v)
+	default:
+		return nil, fmt.Errorf("cannot convert %s to an object", v.Type())
+	}
+}
+
+func fromGoMap(v reflect.Value) (Object, error) {
+	switch v.Type().Key().Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8,
+		reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return nil, fmt.Errorf("cannot convert %s to an object: "+
+			"unusable key type", v.Type())
+	}
+
+	pairs := make(map[HashKey]HashPair, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := fromGo(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		value, err := fromGo(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: value}
+	}
+	return &Hash{Pairs: pairs}, nil
+}
+
+// fromJSON converts a value through its JSON encoding, keeping
+// the numbers that are integers as such
+func fromJSON(v reflect.Value) (Object, error) {
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %s to an object: %s", v.Type(), err)
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %s to an object: %s", v.Type(), err)
+	}
+	return fromDecodedJSON(value)
+}
+
+func fromDecodedJSON(value interface{}) (Object, error) {
+	switch value := value.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+			return &Integer{Value: i}, nil
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return &Float{Value: f}, nil
+	case []interface{}:
+		elements := make([]Object, len(value))
+		for i, v := range value {
+			element, err := fromDecodedJSON(v)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &Array{Elements: elements}, nil
+	case map[string]interface{}:
+		pairs := make(map[HashKey]HashPair, len(value))
+		for k, v := range value {
+			key := &String{Value: k}
+			element, err := fromDecodedJSON(v)
+			if err != nil {
+				return nil, err
+			}
+			pairs[key.HashKey()] = HashPair{Key: key, Value: element}
+		}
+		return &Hash{Pairs: pairs}, nil
+	default:
+		return FromGo(value)
+	}
+}
+
+// fromGoFunc wraps a Go func in a Builtin
+func fromGoFunc(fn reflect.Value) (Object, error) {
+	t := fn.Type()
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	if t.NumOut() > 2 || t.NumOut() == 2 && !returnsError {
+		return nil, fmt.Errorf("cannot convert %s to an object: "+
+			"a func must return at most a value and an error", t)
+	}
+
+	return &Builtin{
+		Fn: func(ctx *Context, args ...Object) Object {
+			in, err := goArguments(t, args)
+			if err != nil {
+				return newError("%s", err)
+			}
+			out, err := callGoFunc(fn, in)
+			if err != nil {
+				return newError("%s", err)
+			}
+
+			if returnsError {
+				if err, _ := out[len(out)-1].Interface().(error); err != nil {
+					return newError("%s", err)
+				}
+				out = out[:len(out)-1]
+			}
+			if len(out) == 0 {
+				return nil
+			}
+			result, err := fromGo(out[0])
+			if err != nil {
+				return newError("%s", err)
+			}
+			return result
+		},
+	}, nil
+}
+
+// callGoFunc calls fn, turning a panic in it into an error so
+// that it fails the script instead of the host
+func callGoFunc(fn reflect.Value, in []reflect.Value) (out []reflect.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in Go function: %v", r)
+		}
+	}()
+	return fn.Call(in), nil
+}
+
+// goArguments converts the arguments of a call to the
+// parameter types of a func
+func goArguments(t reflect.Type, args []Object) ([]reflect.Value, error) {
+	want := t.NumIn()
+	if t.IsVariadic() {
+		if len(args) < want-1 {
+			return nil, fmt.Errorf("wrong number of arguments, "+
+				"got=%d, want at least %d", len(args), want-1)
+		}
+	} else if len(args) != want {
+		return nil, fmt.Errorf("wrong number of arguments, "+
+			"got=%d, want=%d", len(args), want)
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var paramType reflect.Type
+		if t.IsVariadic() && i >= want-1 {
+			paramType = t.In(want - 1).Elem()
+		} else {
+			paramType = t.In(i)
+		}
+		value, err := toGo(arg, paramType)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %s", i+1, err)
+		}
+		in[i] = value
+	}
+	return in, nil
+}
+
+// ToGo converts an object to a Go value: Integer, Float, Boolean
+// and String become int64, float64, bool and string, NULL becomes
+// nil, Array becomes []interface{} and Hash becomes
+// map[string]interface{}, or map[interface{}]interface{} if not
+// all of its keys are strings. An Error is returned as the error.
+func ToGo(obj Object) (interface{}, error) {
+	switch obj := obj.(type) {
+	case nil, *Null:
+		return nil, nil
+	case *Integer:
+		return obj.Value, nil
+	case *Float:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := ToGo(element)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *Hash:
+		return hashToGo(obj)
+	case *Error:
+		return nil, errors.New(obj.Message)
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a Go value", obj.Type())
+	}
+}
+
+func hashToGo(hash *Hash) (interface{}, error) {
+	stringKeys := true
+	for _, pair := range hash.Pairs {
+		if _, ok := pair.Key.(*String); !ok {
+			stringKeys = false
+			break
+		}
+	}
+
+	if stringKeys {
+		m := make(map[string]interface{}, len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			value, err := ToGo(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			m[pair.Key.(*String).Value] = value
+		}
+		return m, nil
+	}
+
+	m := make(map[interface{}]interface{}, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		key, err := ToGo(pair.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := ToGo(pair.Value)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// toGo converts an object to a value of type t
+func toGo(obj Object, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		value, err := ToGo(obj)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(value), nil
+	}
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj), nil
+	}
+	if _, ok := obj.(*Null); ok {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			return reflect.Zero(t), nil
+		}
+	}
+
+	mismatch := fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := obj.(*Boolean)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+		return reflect.ValueOf(b.Value).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+		value := reflect.New(t).Elem()
+		if value.OverflowInt(i.Value) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		value.SetInt(i.Value)
+		return value, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+		value := reflect.New(t).Elem()
+		if i.Value < 0 || value.OverflowUint(uint64(i.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		value.SetUint(uint64(i.Value))
+		return value, nil
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *Integer:
+			return reflect.ValueOf(float64(n.Value)).Convert(t), nil
+		case *Float:
+			return reflect.ValueOf(n.Value).Convert(t), nil
+		}
+		return reflect.Value{}, mismatch
+	case reflect.String:
+		s, ok := obj.(*String)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+		return reflect.ValueOf(s.Value).Convert(t), nil
+	case reflect.Slice:
+		array, ok := obj.(*Array)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+		slice := reflect.MakeSlice(t, len(array.Elements), len(array.Elements))
+		for i, element := range array.Elements {
+			value, err := toGo(element, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			slice.Index(i).Set(value)
+		}
+		return slice, nil
+	case reflect.Map:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+		m := reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			key, err := toGo(pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			value, err := toGo(pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			m.SetMapIndex(key, value)
+		}
+		return m, nil
+	case reflect.Ptr:
+		value, err := toGo(obj, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(value)
+		return ptr, nil
+	case reflect.Struct:
+		return toJSON(obj, t)
+	default:
+		return reflect.Value{}, mismatch
+	}
+}
+
+// toJSON converts an object to a value of type t through JSON
+func toJSON(obj Object, t reflect.Type) (reflect.Value, error) {
+	value, err := ToGo(obj)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("cannot use %s as %s: %s", obj.Type(), t, err)
+	}
+	ptr := reflect.New(t)
+	err = json.Unmarshal(data, ptr.Interface())
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("cannot use %s as %s: %s", obj.Type(), t, err)
+	}
+	return ptr.Elem(), nil
+}
+
+func nativeBool(b bool) *Boolean {
+	if b {
+		return TRUE
+	}
+	return FALSE
+}
@@ -0,0 +1,259 @@
+// Package object object/convert_test.go
+package object
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip,omitempty"`
+}
+
+type testUser struct {
+	Name    string       `json:"name"`
+	Age     int          `json:"age"`
+	Score   float64      `json:"score"`
+	Admin   bool         `json:"is_admin"`
+	Tags    []string     `json:"tags"`
+	Address *testAddress `json:"address"`
+	Secret  string       `json:"-"`
+	Plain   int
+}
+
+func TestFromGo(t *testing.T) {
+	n := 5
+	var nilPtr *int
+	var nilSlice []int
+
+	tests := []struct {
+		input    interface{}
+		expected interface{}
+	}{
+		{nil, nil},
+		{true, true},
+		{42, int64(42)},
+		{int8(-3), int64(-3)},
+		{uint16(7), int64(7)},
+		{3.5, 3.5},
+		{float32(0.5), 0.5},
+		{"monkey", "monkey"},
+		{[]int{1, 2}, []interface{}{int64(1), int64(2)}},
+		{[2]string{"a", "b"}, []interface{}{"a", "b"}},
+		{nilSlice, nil},
+		{&n, int64(5)},
+		{nilPtr, nil},
+		{[]interface{}{1, "two", nil}, []interface{}{int64(1), "two", nil}},
+		{
+			map[string]int{"one": 1, "two": 2},
+			map[string]interface{}{"one": int64(1), "two": int64(2)},
+		},
+		{
+			map[int]bool{1: true},
+			map[interface{}]interface{}{int64(1): true},
+		},
+		{
+			testUser{
+				Name:    "ann",
+				Age:     30,
+				Score:   1.5,
+				Admin:   true,
+				Tags:    []string{"x"},
+				Address: &testAddress{City: "Oslo"},
+				Secret:  "hidden",
+				Plain:   1,
+			},
+			map[string]interface{}{
+				"name":     "ann",
+				"age":      int64(30),
+				"score":    1.5,
+				"is_admin": true,
+				"tags":     []interface{}{"x"},
+				"address":  map[string]interface{}{"city": "Oslo"},
+				"Plain":    int64(1),
+			},
+		},
+		{&Integer{Value: 9}, int64(9)},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Fatalf("FromGo(%#v) error: %s", tt.input, err)
+		}
+		value, err := ToGo(obj)
+		if err != nil {
+			t.Fatalf("ToGo(%s) error: %s", obj.Inspect(), err)
+		}
+		if !reflect.DeepEqual(value, tt.expected) {
+			t.Errorf("wrong conversion of %#v. want=%#v, got=%#v",
+				tt.input, tt.expected, value)
+		}
+	}
+}
+
+func TestFromGoCanonicalObjects(t *testing.T) {
+	for input, expected := range map[interface{}]Object{
+		true:  TRUE,
+		false: FALSE,
+		nil:   NULL,
+	} {
+		obj, err := FromGo(input)
+		if err != nil || obj != expected {
+			t.Errorf("FromGo(%v) is not the canonical %s", input, expected.Inspect())
+		}
+	}
+}
+
+func TestFromGoErrors(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{uint64(math.MaxUint64), "18446744073709551615 overflows INTEGER"},
+		{map[float64]int{1: 1}, "cannot convert map[float64]int to an object: unusable key type"},
+		{make(chan int), "cannot convert chan int to an object"},
+		{[]interface{}{make(chan int)}, "cannot convert chan int to an object"},
+		{func() (int, int) { return 1, 2 }, "a func must return at most a value and an error"},
+	}
+
+	for _, tt := range tests {
+		_, err := FromGo(tt.input)
+		if err == nil || !strings.HasSuffix(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %T. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestToGoErrors(t *testing.T) {
+	tests := []struct {
+		input    Object
+		expected string
+	}{
+		{&Error{Message: "failed"}, "failed"},
+		{&Closure{Fn: &CompiledFunction{}}, "cannot convert CLOSURE to a Go value"},
+		{&Array{Elements: []Object{&Builtin{}}}, "cannot convert BUILTIN to a Go value"},
+	}
+
+	for _, tt := range tests {
+		_, err := ToGo(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. want=%q, got=%v", tt.input.Type(), tt.expected, err)
+		}
+	}
+}
+
+func TestFromGoFunc(t *testing.T) {
+	funcs := map[string]interface{}{
+		"repeat": strings.Repeat,
+		"half": func(x float64) (float64, error) {
+			if x < 0 {
+				return 0, errors.New("negative")
+			}
+			return x / 2, nil
+		},
+		"sum": func(first int, rest ...int) int {
+			for _, n := range rest {
+				first += n
+			}
+			return first
+		},
+		"greet": func(u testUser) string {
+			return u.Name + " from " + u.Address.City
+		},
+		"keys": func(m map[string]int) []string {
+			keys := []string{}
+			for k := range m {
+				keys = append(keys, k)
+			}
+			return keys
+		},
+		"small":   func(b int8) int8 { return b },
+		"any":     func(v interface{}) bool { return v == nil },
+		"object":  func(o Object) Object { return o },
+		"nothing": func() {},
+		"at":      func(xs []int, i int) int { return xs[i] },
+		"store": func(k string) int {
+			var m map[string]int
+			m[k] = 1
+			return 1
+		},
+	}
+
+	user := &Hash{Pairs: map[HashKey]HashPair{}}
+	for k, v := range map[string]Object{
+		"name":    &String{Value: "ann"},
+		"address": &Hash{Pairs: map[HashKey]HashPair{}},
+	} {
+		key := &String{Value: k}
+		user.Pairs[key.HashKey()] = HashPair{Key: key, Value: v}
+	}
+	address := user.Pairs[(&String{Value: "address"}).HashKey()].Value.(*Hash)
+	city := &String{Value: "city"}
+	address.Pairs[city.HashKey()] = HashPair{Key: city, Value: &String{Value: "Oslo"}}
+
+	one := &String{Value: "one"}
+	counts := &Hash{Pairs: map[HashKey]HashPair{
+		one.HashKey(): {Key: one, Value: &Integer{Value: 1}},
+	}}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"repeat", []Object{&String{Value: "ab"}, &Integer{Value: 2}}, "abab"},
+		{"half", []Object{&Integer{Value: 3}}, "1.5"},
+		{"half", []Object{&Float{Value: -1}}, "ERROR: negative"},
+		{"sum", []Object{&Integer{Value: 1}}, "1"},
+		{"sum", []Object{&Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}}, "6"},
+		{"greet", []Object{user}, "ann from Oslo"},
+		{"keys", []Object{counts}, "[one]"},
+		{"small", []Object{&Integer{Value: 100}}, "100"},
+		{"small", []Object{&Integer{Value: 300}}, "ERROR: argument 1: 300 overflows int8"},
+		{"any", []Object{NULL}, "true"},
+		{"object", []Object{&String{Value: "same"}}, "same"},
+		{"nothing", nil, "<nil>"},
+		{"repeat", []Object{&String{Value: "ab"}}, "ERROR: wrong number of arguments, got=1, want=2"},
+		{"sum", nil, "ERROR: wrong number of arguments, got=0, want at least 1"},
+		{
+			"repeat",
+			[]Object{&Integer{Value: 1}, &Integer{Value: 2}},
+			"ERROR: argument 1: cannot use INTEGER as string",
+		},
+		{
+			"at",
+			[]Object{&Array{Elements: []Object{&Integer{Value: 1}}}, &Integer{Value: 3}},
+			"ERROR: panic in Go function: runtime error: index out of range [3] with length 1",
+		},
+		{
+			"store",
+			[]Object{&String{Value: "k"}},
+			"ERROR: panic in Go function: assignment to entry in nil map",
+		},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(funcs[tt.name])
+		if err != nil {
+			t.Fatalf("FromGo(%s) error: %s", tt.name, err)
+		}
+		builtin, ok := obj.(*Builtin)
+		if !ok {
+			t.Fatalf("FromGo(%s) is not a builtin. got=%T", tt.name, obj)
+		}
+
+		result := builtin.Fn(nil, tt.args...)
+		got := "<nil>"
+		if result != nil {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result of %s. want=%q, got=%q", tt.name, tt.expected, got)
+		}
+	}
+}
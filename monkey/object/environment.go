@@ -1,6 +1,8 @@
 // Package object object/environment.go
 package object
 
+import "sort"
+
 // NewEnvironment method
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
@@ -18,8 +20,14 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 
 // Environment struct
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store    map[string]Object
+	outer    *Environment
+	context  *Context
+	builtins *Registry
+	meter    *Meter
+	// depth is the number of nested calls of the function whose
+	// call the environment holds, 0 outside of a function
+	depth int
 }
 
 // Get object method
@@ -31,8 +39,101 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// Assign object method rebinds name in the environment that
+// defines it, and reports false if no environment does
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return val, true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return nil, false
+}
+
 // Set object method
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Names returns the sorted names bound in the environment itself,
+// not in its outer environments
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetContext sets the context of the builtins called in the
+// environment and the environments it encloses
+func (e *Environment) SetContext(ctx *Context) {
+	e.context = ctx
+}
+
+// Context returns the context of the builtins called in the
+// environment, StandardContext if none is set
+func (e *Environment) Context() *Context {
+	for env := e; env != nil; env = env.outer {
+		if env.context != nil {
+			return env.context
+		}
+	}
+	return StandardContext
+}
+
+// SetBuiltins sets the builtins that the identifiers of the
+// environment and the environments it encloses resolve to when
+// they are not bound
+func (e *Environment) SetBuiltins(r *Registry) {
+	e.builtins = r
+}
+
+// Builtins returns the builtins of the environment,
+// StandardRegistry if none are set
+func (e *Environment) Builtins() *Registry {
+	for env := e; env != nil; env = env.outer {
+		if env.builtins != nil {
+			return env.builtins
+		}
+	}
+	return StandardRegistry
+}
+
+// SetMeter sets the meter that counts the nodes evaluated in the
+// environment and the environments it encloses, or removes it
+// when m is nil
+func (e *Environment) SetMeter(m *Meter) {
+	e.meter = m
+}
+
+// Meter returns the meter of the environment, nil if none is set
+func (e *Environment) Meter() *Meter {
+	for env := e; env != nil; env = env.outer {
+		if env.meter != nil {
+			return env.meter
+		}
+	}
+	return nil
+}
+
+// SetDepth sets the number of nested calls of the function whose
+// call the environment holds
+func (e *Environment) SetDepth(depth int) {
+	e.depth = depth
+}
+
+// Depth returns the number of nested function calls that evaluate
+// in the environment
+func (e *Environment) Depth() int {
+	for env := e; env != nil; env = env.outer {
+		if env.depth > 0 {
+			return env.depth
+		}
+	}
+	return 0
+}
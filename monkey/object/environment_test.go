@@ -0,0 +1,33 @@
+// Package object object/environment_test.go
+package object
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvironmentDepth(t *testing.T) {
+	global := NewEnvironment()
+	call := NewEnclosedEnvironment(global)
+	call.SetDepth(2)
+	block := NewEnclosedEnvironment(call)
+
+	for env, expected := range map[*Environment]int{global: 0, call: 2, block: 2} {
+		if env.Depth() != expected {
+			t.Errorf("wrong depth. want=%d, got=%d", expected, env.Depth())
+		}
+	}
+}
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("outer", &Integer{Value: 1})
+	env := NewEnclosedEnvironment(outer)
+	env.Set("b", &Integer{Value: 2})
+	env.Set("a", &Integer{Value: 3})
+
+	expected := []string{"a", "b"}
+	if !reflect.DeepEqual(env.Names(), expected) {
+		t.Errorf("wrong names. want=%q, got=%q", expected, env.Names())
+	}
+}
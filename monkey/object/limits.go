@@ -0,0 +1,179 @@
+// Package object object/limits.go
+package object
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errors of an AbortError when a limit is exceeded
+var (
+	// ErrInstructionLimit is the error when Limits.MaxInstructions is exceeded
+	ErrInstructionLimit = errors.New("instruction limit exceeded")
+	// ErrTimeout is the error when Limits.Timeout is exceeded
+	ErrTimeout = errors.New("execution timed out")
+	// ErrMemoryLimit is the error when Limits.MaxMemory is exceeded
+	ErrMemoryLimit = errors.New("memory limit exceeded")
+)
+
+// DefaultMaxDepth is the number of nested function calls a run may
+// make when Limits.MaxDepth is zero
+const DefaultMaxDepth = 10000
+
+// checkInterval is the number of steps between checks of the
+// clock and of the context
+const checkInterval = 1024
+
+// Limits bound the execution of a program by the host. A zero
+// field sets no limit, except MaxDepth.
+type Limits struct {
+	// MaxInstructions is the number of VM instructions, or of
+	// nodes the evaluator evaluates, that a run may take
+	MaxInstructions int64
+	// Timeout is the wall-clock time that a run may take
+	Timeout time.Duration
+	// MaxMemory is the number of bytes that a run may allocate for
+	// arrays, hashes and the pairs added to them, strings, closures
+	// and call frames, including the values that builtins return, as
+	// estimated by SizeOf, HashPairSize and FrameSize. The literals
+	// of the program are not counted. Memory that becomes free is
+	// not given back.
+	MaxMemory int64
+	// MaxDepth is the number of nested function calls a run may
+	// make, DefaultMaxDepth if zero, so that deep recursion fails
+	// with a runtime error rather than crashing the host
+	MaxDepth int
+}
+
+// CallDepth returns MaxDepth, or DefaultMaxDepth if it is zero
+func (l Limits) CallDepth() int {
+	if l.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return l.MaxDepth
+}
+
+// AbortError is returned when the host stops a program, by a limit
+// or by canceling its context, rather than the program failing
+type AbortError struct {
+	// Err is ErrInstructionLimit, ErrTimeout, ErrMemoryLimit, or
+	// the error of the context, e.g. context.Canceled
+	Err error
+	// Stack holds the innermost frame first
+	Stack []StackFrame
+}
+
+// Error interface method
+func (ae *AbortError) Error() string {
+	return withLocation(ae.Err.Error(), ae.Stack)
+}
+
+// Unwrap returns the underlying error
+func (ae *AbortError) Unwrap() error {
+	return ae.Err
+}
+
+// Traceback returns the stack trace, most recent call last
+func (ae *AbortError) Traceback() string {
+	return formatTraceback(ae.Err.Error(), ae.Stack)
+}
+
+// Meter counts the steps and allocations of a run against its
+// limits and context
+type Meter struct {
+	ctx             context.Context
+	maxInstructions int64
+	deadline        time.Time
+	steps           int64
+	maxMemory       int64
+	allocated       int64
+	maxDepth        int
+}
+
+// NewMeter returns a meter for a run starting now
+func NewMeter(ctx context.Context, limits Limits) *Meter {
+	m := &Meter{
+		ctx:             ctx,
+		maxInstructions: limits.MaxInstructions,
+		maxMemory:       limits.MaxMemory,
+		maxDepth:        limits.CallDepth(),
+	}
+	if limits.Timeout > 0 {
+		m.deadline = time.Now().Add(limits.Timeout)
+	}
+	return m
+}
+
+// Step counts a step. It returns an *AbortError if a limit is
+// exceeded or the context is done, which is checked on the first
+// step and then every checkInterval steps.
+func (m *Meter) Step() error {
+	m.steps++
+	if m.maxInstructions > 0 && m.steps > m.maxInstructions {
+		return &AbortError{Err: ErrInstructionLimit}
+	}
+	if m.steps%checkInterval != 1 {
+		return nil
+	}
+	if !m.deadline.IsZero() && time.Now().After(m.deadline) {
+		return &AbortError{Err: ErrTimeout}
+	}
+	select {
+	case <-m.ctx.Done():
+		return &AbortError{Err: m.ctx.Err()}
+	default:
+		return nil
+	}
+}
+
+// Steps returns the number of steps counted
+func (m *Meter) Steps() int64 {
+	return m.steps
+}
+
+// Allocate counts size bytes allocated. It returns an *AbortError
+// if the memory limit is exceeded.
+func (m *Meter) Allocate(size int64) error {
+	m.allocated += size
+	if m.maxMemory > 0 && m.allocated > m.maxMemory {
+		return &AbortError{Err: ErrMemoryLimit}
+	}
+	return nil
+}
+
+// Allocated returns the number of bytes counted
+func (m *Meter) Allocated() int64 {
+	return m.allocated
+}
+
+// MaxDepth returns the number of nested function calls the run
+// may make
+func (m *Meter) MaxDepth() int {
+	return m.maxDepth
+}
+
+// FrameSize is the estimated size of the frame of a function call
+const FrameSize = 64
+
+// HashPairSize is the estimated size of a pair in a hash
+const HashPairSize = 64
+
+// SizeOf estimates the bytes allocated for obj, not counting the
+// objects it holds, which are counted when they are created
+func SizeOf(obj Object) int64 {
+	switch obj := obj.(type) {
+	case *String:
+		return 16 + int64(len(obj.Value))
+	case *Array:
+		return 24 + 16*int64(len(obj.Elements))
+	case *Hash:
+		return 48 + HashPairSize*int64(len(obj.Pairs))
+	case *Closure:
+		return 32 + 16*int64(len(obj.Free))
+	case *Function:
+		return 64
+	default:
+		return 16
+	}
+}
@@ -0,0 +1,127 @@
+// Package object object/limits_test.go
+package object
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMeter(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		ctx      context.Context
+		limits   Limits
+		steps    int64
+		expected error
+	}{
+		{context.Background(), Limits{}, 10000, nil},
+		{context.Background(), Limits{MaxInstructions: 100}, 100, nil},
+		{context.Background(), Limits{MaxInstructions: 100}, 101, ErrInstructionLimit},
+		{context.Background(), Limits{Timeout: time.Nanosecond}, 1, ErrTimeout},
+		{canceled, Limits{}, 1, context.Canceled},
+	}
+
+	for _, tt := range tests {
+		if tt.limits.Timeout > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		m := NewMeter(tt.ctx, tt.limits)
+		var err error
+		for i := int64(0); i < tt.steps && err == nil; i++ {
+			err = m.Step()
+		}
+		if tt.expected == nil {
+			if err != nil {
+				t.Errorf("unexpected error after %d steps: %s", m.Steps(), err)
+			}
+			continue
+		}
+		abortErr, ok := err.(*AbortError)
+		if !ok || !errors.Is(err, tt.expected) {
+			t.Errorf("wrong error. want=%v, got=%v", tt.expected, err)
+			continue
+		}
+		if m.Steps() != tt.steps {
+			t.Errorf("stopped after %d steps, want %d", m.Steps(), tt.steps)
+		}
+		if abortErr.Error() != tt.expected.Error() {
+			t.Errorf("wrong message. got=%q", abortErr.Error())
+		}
+	}
+}
+
+func TestMeterAllocate(t *testing.T) {
+	m := NewMeter(context.Background(), Limits{MaxMemory: 100})
+	if err := m.Allocate(60); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := m.Allocate(40); err != nil {
+		t.Fatalf("unexpected error at the limit: %s", err)
+	}
+	err := m.Allocate(1)
+	if _, ok := err.(*AbortError); !ok || !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("expected ErrMemoryLimit, got %v", err)
+	}
+	if m.Allocated() != 101 {
+		t.Errorf("wrong allocated bytes. want=101, got=%d", m.Allocated())
+	}
+
+	unlimited := NewMeter(context.Background(), Limits{})
+	if err := unlimited.Allocate(1 << 40); err != nil {
+		t.Errorf("unexpected error without a limit: %s", err)
+	}
+}
+
+func TestSizeOf(t *testing.T) {
+	tests := []struct {
+		obj      Object
+		expected int64
+	}{
+		{&Integer{Value: 1}, 16},
+		{&String{Value: "monkey"}, 22},
+		{&Array{Elements: []Object{TRUE, FALSE}}, 56},
+		{&Hash{Pairs: map[HashKey]HashPair{{}: {}}}, 112},
+		{&Closure{Fn: &CompiledFunction{}, Free: []Object{NULL}}, 48},
+		{&Function{}, 64},
+	}
+
+	for _, tt := range tests {
+		if size := SizeOf(tt.obj); size != tt.expected {
+			t.Errorf("wrong size of %s. want=%d, got=%d", tt.obj.Type(), tt.expected, size)
+		}
+	}
+}
+
+func TestCallDepth(t *testing.T) {
+	if depth := (Limits{}).CallDepth(); depth != DefaultMaxDepth {
+		t.Errorf("wrong default depth. want=%d, got=%d", DefaultMaxDepth, depth)
+	}
+	if depth := (Limits{MaxDepth: 5}).CallDepth(); depth != 5 {
+		t.Errorf("wrong depth. want=5, got=%d", depth)
+	}
+	if depth := NewMeter(context.Background(), Limits{MaxDepth: 5}).MaxDepth(); depth != 5 {
+		t.Errorf("wrong meter depth. want=5, got=%d", depth)
+	}
+}
+
+func TestMeterChecksContextPeriodically(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMeter(ctx, Limits{})
+	m.Step()
+	cancel()
+
+	var err error
+	for i := 0; i < checkInterval && err == nil; i++ {
+		err = m.Step()
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if m.Steps() != checkInterval+1 {
+		t.Errorf("canceled after %d steps, want %d", m.Steps(), checkInterval+1)
+	}
+}
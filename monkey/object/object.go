@@ -5,8 +5,11 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"monkey/ast"
 	"monkey/code"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -16,6 +19,8 @@ type ObjectType string
 const (
 	// INTEGER_OBJ const
 	INTEGER_OBJ = "INTEGER"
+	// FLOAT_OBJ const
+	FLOAT_OBJ = "FLOAT"
 	// BOOLEAN_OBJ const
 	BOOLEAN_OBJ = "BOOLEAN"
 	// NULL_OBJ const
@@ -38,6 +43,20 @@ const (
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
 	// CLOSURE_OBJ const
 	CLOSURE_OBJ = "CLOSURE"
+	// ITERATOR_OBJ const
+	ITERATOR_OBJ = "ITERATOR"
+	// BREAK_OBJ const
+	BREAK_OBJ = "BREAK"
+	// CONTINUE_OBJ const
+	CONTINUE_OBJ = "CONTINUE"
+	// TAIL_CALL_OBJ const
+	TAIL_CALL_OBJ = "TAIL_CALL"
+	// CELL_OBJ const
+	CELL_OBJ = "CELL"
+	// QUOTE_OBJ const
+	QUOTE_OBJ = "QUOTE"
+	// MACRO_OBJ const
+	MACRO_OBJ = "MACRO"
 )
 
 // Object interface
@@ -61,11 +80,41 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+// Float struct
+type Float struct {
+	Value float64
+}
+
+// Type interface method
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Inspect interface method
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") {
+		return s
+	}
+	return s + ".0"
+}
+
 // Boolean struct
 type Boolean struct {
 	Value bool
 }
 
+// the boolean and null objects, which the engines
+// compare by identity
+var (
+	// TRUE var
+	TRUE = &Boolean{Value: true}
+	// FALSE var
+	FALSE = &Boolean{Value: false}
+	// NULL var
+	NULL = &Null{}
+)
+
 // Type interface method
 func (b *Boolean) Type() ObjectType {
 	return BOOLEAN_OBJ
@@ -107,6 +156,11 @@ func (rv *ReturnValue) Inspect() string {
 // Error struct
 type Error struct {
 	Message string
+	// Stack is filled in by the evaluator as the error
+	// unwinds, innermost frame first
+	Stack []StackFrame
+	// Err is the *AbortError if the host stopped the program
+	Err error
 }
 
 // Type interface method
@@ -119,11 +173,17 @@ func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// Traceback returns the stack trace, most recent call last
+func (e *Error) Traceback() string {
+	return formatTraceback(e.Message, e.Stack)
+}
+
 // Function struct
 type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Name       string
 }
 
 // Type interface method
@@ -149,6 +209,51 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// Quote struct wraps an unevaluated AST node
+type Quote struct {
+	Node ast.Node
+}
+
+// Type interface method
+func (q *Quote) Type() ObjectType {
+	return QUOTE_OBJ
+}
+
+// Inspect interface method
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+// Macro struct
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+// Type interface method
+func (m *Macro) Type() ObjectType {
+	return MACRO_OBJ
+}
+
+// Inspect interface method
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+	params := []string{}
+
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+	return out.String()
+}
+
 // String struct
 type String struct {
 	Value string
@@ -165,7 +270,7 @@ func (s *String) Inspect() string {
 }
 
 // BuiltinFunction type
-type BuiltinFunction func(args ...Object) Object
+type BuiltinFunction func(ctx *Context, args ...Object) Object
 
 // Builtin struct
 type Builtin struct {
@@ -249,6 +354,37 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// Keys returns the keys of the hash in a stable order:
+// grouped by type, then by value
+func (h *Hash) Keys() []Object {
+	keys := make([]Object, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		keys = append(keys, pair.Key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+	return keys
+}
+
+func keyLess(a, b Object) bool {
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value < b.(*Integer).Value
+	case *Float:
+		return a.Value < b.(*Float).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	default:
+		return a.Inspect() < b.Inspect()
+	}
+}
+
 // HashKey interface method
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
@@ -265,6 +401,16 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// HashKey interface method. A float with an integral value has the
+// key of the equal integer, so that 1.0 finds the pair of 1 and 0.0
+// that of -0.0.
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return (&Integer{Value: int64(f.Value)}).HashKey()
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 // HashKey interface method
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
@@ -274,9 +420,11 @@ func (s *String) HashKey() HashKey {
 
 // CompiledFunction struct
 type CompiledFunction struct {
+	Name          string
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+	SourceMap     code.SourceMap
 }
 
 // Type func
@@ -304,3 +452,104 @@ func (c *Closure) Type() ObjectType {
 func (c *Closure) Inspect() string {
 	return fmt.Sprintf("Closure[%p]", c)
 }
+
+// Cell struct boxes a variable captured by a closure, so the
+// closure and the enclosing function share every assignment
+type Cell struct {
+	Value Object
+}
+
+// Type func
+func (c *Cell) Type() ObjectType {
+	return CELL_OBJ
+}
+
+// Inspect func
+func (c *Cell) Inspect() string {
+	return c.Value.Inspect()
+}
+
+// Iterator struct steps through the elements of an array
+// or the keys of a hash in a for loop
+type Iterator struct {
+	Elements []Object
+	Index    int
+}
+
+// NewIterator returns an iterator over obj, or false if
+// obj cannot be iterated over
+func NewIterator(obj Object) (*Iterator, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		elements := make([]Object, len(obj.Elements))
+		copy(elements, obj.Elements)
+		return &Iterator{Elements: elements}, true
+	case *Hash:
+		return &Iterator{Elements: obj.Keys()}, true
+	default:
+		return nil, false
+	}
+}
+
+// Next returns the next element, or false when there are none left
+func (it *Iterator) Next() (Object, bool) {
+	if it.Index >= len(it.Elements) {
+		return nil, false
+	}
+	element := it.Elements[it.Index]
+	it.Index++
+	return element, true
+}
+
+// Type func
+func (it *Iterator) Type() ObjectType {
+	return ITERATOR_OBJ
+}
+
+// Inspect func
+func (it *Iterator) Inspect() string {
+	return fmt.Sprintf("Iterator[%p]", it)
+}
+
+// Break struct signals a break statement in the evaluator
+type Break struct{}
+
+// Type interface method
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+// Inspect interface method
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+// Continue struct signals a continue statement in the evaluator
+type Continue struct{}
+
+// Type interface method
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+// Inspect interface method
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+// TailCall signals a call in tail position in the evaluator, which
+// the function making it returns for its caller to make
+type TailCall struct {
+	Fn   *Function
+	Args []Object
+}
+
+// Type interface method
+func (tc *TailCall) Type() ObjectType {
+	return TAIL_CALL_OBJ
+}
+
+// Inspect interface method
+func (tc *TailCall) Inspect() string {
+	return "tail call of " + tc.Fn.Inspect()
+}
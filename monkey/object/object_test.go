@@ -1,7 +1,10 @@
 // Package object object/object_test.go
 package object
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -39,6 +42,26 @@ func TestBooleanHashKey(t *testing.T) {
 		t.Errorf("Boolean with different content have same hash keys")
 	}
 }
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1, "1.0"},
+		{1.5, "1.5"},
+		{-0.25, "-0.25"},
+		{1e21, "1e+21"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect for %g, want=%q, got=%q",
+				tt.value, tt.expected, f.Inspect())
+		}
+	}
+}
+
 func TestIntegerHashKey(t *testing.T) {
 	hello1 := &Integer{Value: 1}
 	hello2 := &Integer{Value: 1}
@@ -57,3 +80,28 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("Integer with different content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	tests := []struct {
+		float    float64
+		other    Object
+		expected bool
+	}{
+		{1.5, &Float{Value: 1.5}, true},
+		{1.5, &Float{Value: 2.5}, false},
+		{1.0, &Integer{Value: 1}, true},
+		{-3.0, &Integer{Value: -3}, true},
+		{0.0, &Float{Value: math.Copysign(0, -1)}, true},
+		{math.Copysign(0, -1), &Integer{Value: 0}, true},
+		{1.5, &Integer{Value: 1}, false},
+		{1e300, &Float{Value: 1e300}, true},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.float}
+		if equal := f.HashKey() == tt.other.(Hashable).HashKey(); equal != tt.expected {
+			t.Errorf("HashKey of %g == HashKey of %s: want=%t, got=%t",
+				tt.float, tt.other.Inspect(), tt.expected, equal)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package object object/registry.go
+package object
+
+import "fmt"
+
+// MaxBuiltins is the number of builtins a registry can hold, as
+// OpGetBuiltin has a one byte operand
+const MaxBuiltins = 256
+
+// Registry holds the builtins of an interpreter by name: the
+// standard Builtins, then the functions a host registers with it.
+// The index of a builtin in the registry is the operand of
+// OpGetBuiltin.
+type Registry struct {
+	names    []string
+	builtins []*Builtin
+	index    map[string]int
+}
+
+// NewRegistry returns a registry holding the standard Builtins
+func NewRegistry() *Registry {
+	r := &Registry{index: make(map[string]int)}
+	for _, def := range Builtins {
+		r.Register(def.Name, def.Builtin)
+	}
+	return r
+}
+
+// StandardRegistry is used when no other registry is set. Builtins
+// registered with it are seen by every such interpreter.
+var StandardRegistry = NewRegistry()
+
+// Register adds builtin under name, replacing the builtin of that
+// name if there is one
+func (r *Registry) Register(name string, builtin *Builtin) error {
+	if i, ok := r.index[name]; ok {
+		r.builtins[i] = builtin
+		return nil
+	}
+	if len(r.builtins) == MaxBuiltins {
+		return fmt.Errorf("cannot register %s: too many builtins", name)
+	}
+	r.index[name] = len(r.builtins)
+	r.names = append(r.names, name)
+	r.builtins = append(r.builtins, builtin)
+	return nil
+}
+
+// RegisterFunc registers a host function, which does not use
+// the context of the call, under name
+func (r *Registry) RegisterFunc(name string, fn func(args ...Object) Object) error {
+	return r.Register(name, &Builtin{
+		Fn: func(ctx *Context, args ...Object) Object {
+			return fn(args...)
+		},
+	})
+}
+
+// Lookup returns the builtin registered under name
+func (r *Registry) Lookup(name string) (*Builtin, bool) {
+	i, ok := r.index[name]
+	if !ok {
+		return nil, false
+	}
+	return r.builtins[i], true
+}
+
+// Index returns the index of the builtin registered under name
+func (r *Registry) Index(name string) (int, bool) {
+	i, ok := r.index[name]
+	return i, ok
+}
+
+// Get returns the builtin at index, or nil if there is none
+func (r *Registry) Get(index int) *Builtin {
+	if index < 0 || index >= len(r.builtins) {
+		return nil
+	}
+	return r.builtins[index]
+}
+
+// Names returns the names of the builtins in index order
+func (r *Registry) Names() []string {
+	return append([]string{}, r.names...)
+}
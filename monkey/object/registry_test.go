@@ -0,0 +1,86 @@
+// Package object object/registry_test.go
+package object
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	standard := len(Builtins)
+
+	double := func(args ...Object) Object {
+		return &Integer{Value: args[0].(*Integer).Value * 2}
+	}
+	if err := r.RegisterFunc("double", double); err != nil {
+		t.Fatalf("RegisterFunc error: %s", err)
+	}
+
+	i, ok := r.Index("double")
+	if !ok || i != standard {
+		t.Errorf("wrong index of double. want=%d, got=%d (%t)", standard, i, ok)
+	}
+	result := r.Get(i).Fn(nil, &Integer{Value: 21})
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result of double. got=%s", result.Inspect())
+	}
+	if _, ok := StandardRegistry.Lookup("double"); ok {
+		t.Errorf("double registered in StandardRegistry")
+	}
+
+	// replacing a builtin keeps its index
+	lenIndex, _ := r.Index("len")
+	replacement := &Builtin{Fn: func(ctx *Context, args ...Object) Object { return nil }}
+	r.Register("len", replacement)
+	if b, _ := r.Lookup("len"); b != replacement {
+		t.Errorf("len not replaced")
+	}
+	if i, _ := r.Index("len"); i != lenIndex {
+		t.Errorf("len moved from %d to %d", lenIndex, i)
+	}
+	if StandardRegistry.Get(lenIndex) == replacement {
+		t.Errorf("len replaced in StandardRegistry")
+	}
+
+	names := r.Names()
+	if len(names) != standard+1 || names[0] != Builtins[0].Name || names[standard] != "double" {
+		t.Errorf("wrong names. got=%q", names)
+	}
+	if r.Get(-1) != nil || r.Get(standard+1) != nil {
+		t.Errorf("Get out of range returned a builtin")
+	}
+}
+
+func TestRegistryIsFull(t *testing.T) {
+	r := NewRegistry()
+	for i := len(Builtins); i < MaxBuiltins; i++ {
+		if err := r.Register(fmt.Sprintf("b%d", i), &Builtin{}); err != nil {
+			t.Fatalf("Register error: %s", err)
+		}
+	}
+	err := r.Register("more", &Builtin{})
+	if err == nil || err.Error() != "cannot register more: too many builtins" {
+		t.Errorf("expected too many builtins error, got %v", err)
+	}
+	if err := r.Register("len", &Builtin{}); err != nil {
+		t.Errorf("replacing a builtin of a full registry failed: %s", err)
+	}
+}
+
+func TestEnvironmentBuiltins(t *testing.T) {
+	r := NewRegistry()
+	outer := NewEnvironment()
+	env := NewEnclosedEnvironment(outer)
+	if env.Builtins() != StandardRegistry {
+		t.Errorf("expected StandardRegistry by default")
+	}
+	outer.SetBuiltins(r)
+	if env.Builtins() != r {
+		t.Errorf("enclosed environment does not use the registry of its outer")
+	}
+	if !reflect.DeepEqual(r.Names(), StandardRegistry.Names()) {
+		t.Errorf("new registry does not hold the standard builtins")
+	}
+}
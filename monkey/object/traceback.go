@@ -0,0 +1,95 @@
+// Package object object/traceback.go
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/code"
+)
+
+// MainFunctionName names the top level of a program in stack traces
+const MainFunctionName = "<main>"
+
+// AnonymousFunctionName names functions not bound by a let statement
+const AnonymousFunctionName = "<anonymous>"
+
+// StackFrame is one entry of a runtime stack trace
+type StackFrame struct {
+	Function string
+	Location code.Location
+}
+
+// String interface method
+func (sf StackFrame) String() string {
+	pos := sf.Location.Span.Start
+	if !pos.IsValid() {
+		return fmt.Sprintf("in %s", sf.Function)
+	}
+	if sf.Location.File == "" {
+		return fmt.Sprintf("line %d, column %d, in %s",
+			pos.Line, pos.Column, sf.Function)
+	}
+	return fmt.Sprintf("File %q, line %d, column %d, in %s",
+		sf.Location.File, pos.Line, pos.Column, sf.Function)
+}
+
+// RuntimeError is an error raised while executing a program,
+// together with the call stack at the point of failure
+type RuntimeError struct {
+	Err error
+	// Stack holds the innermost frame first
+	Stack []StackFrame
+}
+
+// Error interface method
+func (re *RuntimeError) Error() string {
+	return withLocation(re.Err.Error(), re.Stack)
+}
+
+// Unwrap returns the underlying error
+func (re *RuntimeError) Unwrap() error {
+	return re.Err
+}
+
+// Traceback returns the stack trace, most recent call last
+func (re *RuntimeError) Traceback() string {
+	return formatTraceback(re.Err.Error(), re.Stack)
+}
+
+func withLocation(message string, stack []StackFrame) string {
+	if len(stack) == 0 || !stack[0].Location.Span.Start.IsValid() {
+		return message
+	}
+	return fmt.Sprintf("%s: %s", stack[0].Location, message)
+}
+
+// maxRepeatedFrames is the number of equal consecutive frames that
+// a traceback shows before it counts the rest
+const maxRepeatedFrames = 3
+
+func formatTraceback(message string, stack []StackFrame) string {
+	var out bytes.Buffer
+	out.WriteString("Traceback (most recent call last):\n")
+	repeated := 0
+	for i := len(stack) - 1; i >= 0; i-- {
+		if i < len(stack)-1 && stack[i] == stack[i+1] {
+			repeated++
+		} else {
+			writeRepeated(&out, repeated)
+			repeated = 0
+		}
+		if repeated < maxRepeatedFrames {
+			out.WriteString("  " + stack[i].String() + "\n")
+		}
+	}
+	writeRepeated(&out, repeated)
+	out.WriteString(message)
+	return out.String()
+}
+
+func writeRepeated(out *bytes.Buffer, repeated int) {
+	if repeated >= maxRepeatedFrames {
+		fmt.Fprintf(out, "  [previous frame repeated %d more times]\n",
+			repeated-maxRepeatedFrames+1)
+	}
+}
@@ -0,0 +1,51 @@
+// Package object object/traceback_test.go
+package object
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTracebackRepeatedFrames(t *testing.T) {
+	f := StackFrame{Function: "f"}
+	g := StackFrame{Function: "g"}
+	main := StackFrame{Function: MainFunctionName}
+
+	tests := []struct {
+		stack    []StackFrame
+		expected string
+	}{
+		{[]StackFrame{f, f, f, main}, `Traceback (most recent call last):
+  in <main>
+  in f
+  in f
+  in f
+failed`},
+		{[]StackFrame{g, f, f, f, f, f, main}, `Traceback (most recent call last):
+  in <main>
+  in f
+  in f
+  in f
+  [previous frame repeated 2 more times]
+  in g
+failed`},
+		{[]StackFrame{f, f, f, f, g, g, g, g, main}, `Traceback (most recent call last):
+  in <main>
+  in g
+  in g
+  in g
+  [previous frame repeated 1 more times]
+  in f
+  in f
+  in f
+  [previous frame repeated 1 more times]
+failed`},
+	}
+
+	for _, tt := range tests {
+		err := &RuntimeError{Err: errors.New("failed"), Stack: tt.stack}
+		if err.Traceback() != tt.expected {
+			t.Errorf("wrong traceback, expected=\n%s\ngot=\n%s", tt.expected, err.Traceback())
+		}
+	}
+}
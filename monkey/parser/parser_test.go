@@ -146,6 +146,39 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e3;", 1000},
+		{"2.5e-1;", 0.25},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement, got=%T",
+				program.Statements[0])
+		}
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral, got=%T",
+				stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g, got=%g",
+				tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"hello world"`
 	l := lexer.New(input)
@@ -277,6 +310,11 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"true && false", true, "&&", false},
+		{"false || true", false, "||", true},
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
@@ -416,6 +454,22 @@ func TestOperatorPrecedence(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a || b && c || d",
+			"((a || (b && c)) || d)",
+		},
+		{
+			"a == b && c < d || !e",
+			"(((a == b) && (c < d)) || (!e))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -521,6 +575,91 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else { z }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression, got=%T",
+			stmt.Expression)
+	}
+	if len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("alternative is not 1 statement, got=%d\n",
+			len(exp.Alternative.Statements))
+	}
+	alternative, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement, got=%T",
+			exp.Alternative.Statements[0])
+	}
+	nested, ok := alternative.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("alternative is not ast.IfExpression, got=%T",
+			alternative.Expression)
+	}
+	if !testInfixExpression(t, nested.Condition, "x", ">", "y") {
+		return
+	}
+	if nested.Alternative == nil || nested.Alternative.String() != "z" {
+		t.Errorf("nested.Alternative wrong, got=%+v", nested.Alternative)
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (x) {
+	0 => "zero",
+	-1 => "minus one",
+	[a, _] => a,
+	{"name": n} => n,
+	_ => x * 2,
+}`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MatchExpression, got=%T",
+			stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Value, "x") {
+		return
+	}
+
+	tests := []struct {
+		expectedPattern string
+		expectedBody    string
+	}{
+		{"0", "zero"},
+		{"(-1)", "minus one"},
+		{"[a, _]", "a"},
+		{"{name:n}", "n"},
+		{"_", "(x * 2)"},
+	}
+	if len(exp.Arms) != len(tests) {
+		t.Fatalf("exp.Arms does not contain %d arms, got=%d",
+			len(tests), len(exp.Arms))
+	}
+	for i, tt := range tests {
+		arm := exp.Arms[i]
+		if arm.Pattern.String() != tt.expectedPattern {
+			t.Errorf("arms[%d] - pattern wrong, expected=%q, got=%q",
+				i, tt.expectedPattern, arm.Pattern.String())
+		}
+		if arm.Body.String() != tt.expectedBody {
+			t.Errorf("arms[%d] - body wrong, expected=%q, got=%q",
+				i, tt.expectedBody, arm.Body.String())
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 	l := lexer.New(input)
@@ -594,6 +733,53 @@ func TestFunctionLiteralWithName(t *testing.T) {
 	}
 }
 
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T",
+			stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n",
+			len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n",
+			len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T",
+			macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -805,6 +991,242 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	}
 }
 
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedTarget string
+		expectedOp     string
+		expected       string
+	}{
+		{"x = 5;", "x", "=", "x = 5"},
+		{"x += y * 2;", "x", "+=", "x += (y * 2)"},
+		{"x = y = 1;", "x", "=", "x = y = 1"},
+		{"arr[i + 1] -= 1;", "(arr[(i + 1)])", "-=", "(arr[(i + 1)]) -= 1"},
+		{`h["a"] = fn(x) { x };`, "(h[a])", "=", "(h[a]) = fn(x) x"},
+		{"x %= 2 || y", "x", "%=", "x %= (2 || y)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements, "+
+				"got=%d\n", 1, len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement, "+
+				"got=%T", program.Statements[0])
+		}
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.AssignExpression, got=%T",
+				stmt.Expression)
+		}
+		if exp.Target.String() != tt.expectedTarget {
+			t.Errorf("exp.Target wrong, expected=%q, got=%q",
+				tt.expectedTarget, exp.Target.String())
+		}
+		if exp.Operator != tt.expectedOp {
+			t.Errorf("exp.Operator wrong, expected=%q, got=%q",
+				tt.expectedOp, exp.Operator)
+		}
+		if exp.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, exp.String())
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { x; break; }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements, "+
+			"got=%d\n", 1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement, "+
+			"got=%T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "10") {
+		return
+	}
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements, got=%d\n",
+			len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Fatalf("Statements[1] is not ast.BreakStatement, got=%T",
+			stmt.Body.Statements[1])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (x in [1, 2]) { continue; }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements, "+
+			"got=%d\n", 1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement, "+
+			"got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Variable, "x") {
+		return
+	}
+	if stmt.Iterable.String() != "[1, 2]" {
+		t.Errorf("stmt.Iterable wrong, got=%q", stmt.Iterable.String())
+	}
+	if _, ok := stmt.Body.Statements[0].(*ast.ContinueStatement); !ok {
+		t.Fatalf("Statements[0] is not ast.ContinueStatement, got=%T",
+			stmt.Body.Statements[0])
+	}
+	expected := "for (x in [1, 2]) continue;"
+	if program.String() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestParsingWithComments(t *testing.T) {
+	input := `// add two numbers
+let add = fn(a, /* first */ b) {
+	a + b; // sum
+};
+add(1, 2) // call`
+
+	for _, l := range []*lexer.Lexer{lexer.New(input), lexer.NewWithComments(input)} {
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 2 {
+			t.Fatalf("program.Statements does not contain "+
+				"2 statements, got=%d", len(program.Statements))
+		}
+		expected := "let add = fn<add>(a, b) (a + b);add(1, 2)"
+		if program.String() != expected {
+			t.Errorf("expected=%q, got=%q", expected, program.String())
+		}
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x 5;", "1:7: expected next token to be '=', got='INT'"},
+		{"let x = 1;\nlet = 10;", "2:5: expected next token to be 'IDENT', got='='"},
+		{"let x = 1;\n  * 2", "2:3: no prefix parse function for * found"},
+		{"99999999999999999999", "1:1: could not parse \"99999999999999999999\" as integer"},
+		{"let x = 1;\nbreak;", "2:1: break outside loop"},
+		{"x + 1 = 2", "1:7: cannot assign to (x + 1)"},
+		{"match (x) { a + 1 => 2 }", "1:15: invalid pattern (a + 1)"},
+		{"match (x) { {a: 1} => 2 }", "1:14: invalid pattern a"},
+		{"match (x) { 1 => 2 3 => 4 }", "1:20: expected next token to be ',', got='INT'"},
+		{"f() += 1", "1:5: cannot assign to f()"},
+		{"while (true) { fn() { continue; } }", "1:23: continue outside loop"},
+		{"for (1 in x) { }", "1:6: expected next token to be 'IDENT', got='INT'"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error, expected=%q, got=%q",
+				tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestUnexpectedEOF(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"let x =", true},
+		{"let x", true},
+		{"1 +", true},
+		{"if (x)", true},
+		{"fn(a, b)", true},
+		{"match (x)", true},
+		{"let x 5;", false},
+		{"let x = 1;\n  * 2", false},
+		{"x + 1 = 2", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if p.UnexpectedEOF() != tt.expected {
+			t.Errorf("UnexpectedEOF wrong for %q. want=%t, got=%t (errors=%q)",
+				tt.input, tt.expected, p.UnexpectedEOF(), p.Errors())
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + b;
+};
+add(1, 2);`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	fn := let.Value.(*ast.FunctionLiteral)
+	body := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression
+
+	tests := []struct {
+		node           ast.Node
+		expectedLine   int
+		expectedColumn int
+	}{
+		{program, 1, 1},
+		{let, 1, 1},
+		{let.Name, 1, 5},
+		{fn, 1, 11},
+		{fn.Parameters[1], 1, 17},
+		{body, 2, 3},
+		{body.Expression, 2, 5},
+		{call, 4, 4},
+	}
+
+	for i, tt := range tests {
+		pos := tt.node.Pos()
+		if pos.Line != tt.expectedLine || pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - wrong position for %q, expected=%d:%d, got=%s",
+				i, tt.node.String(), tt.expectedLine, tt.expectedColumn, pos)
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let', got=%q",
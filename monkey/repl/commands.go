@@ -0,0 +1,163 @@
+// Package repl repl/commands.go
+package repl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+)
+
+const commandHelp = `:ast <expr>        print the syntax tree of expr
+:bytecode <expr>   print the disassembled bytecode of expr
+:globals           list the global names and their values
+:load <file>       run a script file
+:reset             forget all definitions
+:engine [vm|eval]  show or switch the engine that runs entries
+:time              toggle printing how long entries take
+:help              show this list
+`
+
+// isCommand reports whether line is a colon command
+func isCommand(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), ":")
+}
+
+// command runs a colon command
+func (s *session) command(line string) {
+	fields := strings.Fields(strings.TrimSpace(line))
+	name := fields[0]
+	arg := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), name))
+
+	switch name {
+	case ":ast":
+		program, ok := s.parse(name, arg)
+		if ok {
+			ast.Fprint(s.out, program)
+		}
+	case ":bytecode":
+		program, ok := s.parse(name, arg)
+		if ok {
+			s.bytecode(program)
+		}
+	case ":globals":
+		s.listGlobals()
+	case ":load":
+		if arg == "" {
+			fmt.Fprintf(s.out, "usage: :load <file>\n")
+			return
+		}
+		s.load(arg)
+	case ":reset":
+		s.reset()
+	case ":engine":
+		switch arg {
+		case "":
+		case engineVM, engineEval:
+			s.engine = arg
+		default:
+			fmt.Fprintf(s.out, "unknown engine %q, use %s or %s\n",
+				arg, engineVM, engineEval)
+			return
+		}
+		fmt.Fprintf(s.out, "engine: %s\n", s.engine)
+	case ":time":
+		s.timing = !s.timing
+		if s.timing {
+			fmt.Fprintf(s.out, "timing on\n")
+		} else {
+			fmt.Fprintf(s.out, "timing off\n")
+		}
+	case ":help":
+		fmt.Fprint(s.out, commandHelp)
+	default:
+		fmt.Fprintf(s.out, "unknown command %s, try :help\n", name)
+	}
+}
+
+// parse parses the argument of a command
+func (s *session) parse(name, arg string) (*ast.Program, bool) {
+	if arg == "" {
+		fmt.Fprintf(s.out, "usage: %s <expr>\n", name)
+		return nil, false
+	}
+	p := parser.New(lexer.New(arg))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return nil, false
+	}
+	return program, true
+}
+
+// bytecode prints the bytecode that program compiles to, without
+// defining anything in the session
+func (s *session) bytecode(program *ast.Program) {
+	expanded, err := evaluator.ExpandMacros(program, s.macroEnv)
+	if err != nil {
+		fmt.Fprintf(s.out, "Woops! Macro expansion failed:\n %s\n", err)
+		return
+	}
+
+	constants := append([]object.Object{}, s.constants...)
+	comp := compiler.NewWithState(s.symbolTable.Copy(), constants)
+	err = comp.Compile(expanded)
+	if err != nil {
+		fmt.Fprintf(s.out, "Woops! Compilation failed:\n %s\n", err)
+		return
+	}
+	compiler.Disassemble(s.out, comp.Bytecode())
+}
+
+// listGlobals prints the globals of the current engine
+func (s *session) listGlobals() {
+	if s.engine == engineEval {
+		for _, name := range s.env.Names() {
+			value, _ := s.env.Get(name)
+			fmt.Fprintf(s.out, "%s = %s\n", name, value.Inspect())
+		}
+		return
+	}
+
+	for _, symbol := range s.symbolTable.Symbols(compiler.GlobalScope) {
+		if isHidden(symbol.Name) {
+			continue
+		}
+		value := s.globals[symbol.Index]
+		if value == nil {
+			// defined by an entry that failed before setting it
+			continue
+		}
+		fmt.Fprintf(s.out, "%s = %s\n", symbol.Name, value.Inspect())
+	}
+}
+
+// isHidden reports whether name is one of the slots the compiler
+// defines for itself, such as $iterator, which no identifier can name
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, "$")
+}
+
+// load runs the script in file
+func (s *session) load(file string) {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(s.out, "Woops! %s\n", err)
+		return
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(s.out, "\t%s:%s\n", file, msg)
+		}
+		return
+	}
+	s.run(program, file)
+}
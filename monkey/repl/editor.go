@@ -0,0 +1,456 @@
+// Package repl repl/editor.go
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// keys the editor handles
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// defaultWidth of the terminal if it cannot be queried
+const defaultWidth = 80
+
+// errInterrupt is returned by readLine when the line is abandoned
+var errInterrupt = errors.New("interrupt")
+
+// lineReader reads the lines of the REPL entries
+type lineReader interface {
+	// readLine shows prompt and reads a line. It returns io.EOF at the
+	// end of the input, and errInterrupt if the user abandons the line.
+	readLine(prompt string) (string, error)
+}
+
+// scanReader reads plain lines, e.g. when the input is not a terminal
+type scanReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scanReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// editor reads lines from a terminal with cursor movement, history
+// browsing and search, and tab completion
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	terminal *terminal // nil if the input is already raw
+	history  *history
+	// complete returns the completions of a word prefix
+	complete func(prefix string) []string
+
+	prompt string
+	line   []rune
+	pos    int
+	// browse is the history entry shown, len(history.entries)
+	// for the new line, which is kept in saved meanwhile
+	browse int
+	saved  []rune
+}
+
+func newEditor(
+	in io.Reader,
+	out io.Writer,
+	t *terminal,
+	h *history,
+	complete func(string) []string,
+) *editor {
+	return &editor{
+		in:       bufio.NewReader(in),
+		out:      out,
+		terminal: t,
+		history:  h,
+		complete: complete,
+	}
+}
+
+func (e *editor) readLine(prompt string) (string, error) {
+	if e.terminal != nil {
+		restore, err := e.terminal.makeRaw()
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	e.prompt = prompt
+	e.line = nil
+	e.pos = 0
+	e.browse = len(e.history.entries)
+	e.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, keyLineFeed:
+			return e.accept(), nil
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupt
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.delete()
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.line)
+		case keyCtrlB:
+			e.left()
+		case keyCtrlF:
+			e.right()
+		case keyCtrlH, keyBackspace:
+			if e.pos > 0 {
+				e.pos--
+				e.delete()
+			}
+		case keyCtrlK:
+			e.line = e.line[:e.pos]
+		case keyCtrlU:
+			e.line = append([]rune{}, e.line[e.pos:]...)
+			e.pos = 0
+		case keyCtrlW:
+			e.deleteWord()
+		case keyCtrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP:
+			e.previous()
+		case keyCtrlN:
+			e.next()
+		case keyCtrlR:
+			submit, err := e.search()
+			if err != nil {
+				return "", err
+			}
+			if submit {
+				return e.accept(), nil
+			}
+		case keyTab:
+			e.completeWord()
+		case keyEscape:
+			err := e.escape()
+			if err != nil {
+				return "", err
+			}
+		default:
+			if unicode.IsPrint(r) {
+				e.insert([]rune{r})
+			}
+		}
+		e.refresh()
+	}
+}
+
+// accept ends the line and adds it to the history
+func (e *editor) accept() string {
+	e.pos = len(e.line)
+	e.refresh()
+	io.WriteString(e.out, "\r\n")
+	line := string(e.line)
+	e.history.add(line)
+	return line
+}
+
+// escape handles the escape sequences of the arrow, home, end
+// and delete keys
+func (e *editor) escape() error {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return err
+	}
+	if r != '[' && r != 'O' {
+		return nil
+	}
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return err
+	}
+
+	switch r {
+	case 'A':
+		e.previous()
+	case 'B':
+		e.next()
+	case 'C':
+		e.right()
+	case 'D':
+		e.left()
+	case 'H':
+		e.pos = 0
+	case 'F':
+		e.pos = len(e.line)
+	default:
+		if r < '0' || r > '9' {
+			return nil
+		}
+		// sequences such as ESC [ 3 ~
+		number := string(r)
+		for {
+			r, _, err = e.in.ReadRune()
+			if err != nil {
+				return err
+			}
+			if r < '0' || r > '9' {
+				break
+			}
+			number += string(r)
+		}
+		if r != '~' {
+			return nil
+		}
+		switch number {
+		case "1", "7":
+			e.pos = 0
+		case "4", "8":
+			e.pos = len(e.line)
+		case "3":
+			e.delete()
+		}
+	}
+	return nil
+}
+
+func (e *editor) insert(runes []rune) {
+	line := make([]rune, 0, len(e.line)+len(runes))
+	line = append(line, e.line[:e.pos]...)
+	line = append(line, runes...)
+	e.line = append(line, e.line[e.pos:]...)
+	e.pos += len(runes)
+}
+
+// delete removes the rune under the cursor
+func (e *editor) delete() {
+	if e.pos < len(e.line) {
+		e.line = append(e.line[:e.pos], e.line[e.pos+1:]...)
+	}
+}
+
+// deleteWord removes the word before the cursor
+func (e *editor) deleteWord() {
+	start := e.pos
+	for start > 0 && e.line[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && e.line[start-1] != ' ' {
+		start--
+	}
+	e.line = append(e.line[:start], e.line[e.pos:]...)
+	e.pos = start
+}
+
+func (e *editor) left() {
+	if e.pos > 0 {
+		e.pos--
+	}
+}
+
+func (e *editor) right() {
+	if e.pos < len(e.line) {
+		e.pos++
+	}
+}
+
+// previous shows the previous history entry
+func (e *editor) previous() {
+	if e.browse == 0 {
+		return
+	}
+	if e.browse == len(e.history.entries) {
+		e.saved = e.line
+	}
+	e.browse--
+	e.line = []rune(e.history.entries[e.browse])
+	e.pos = len(e.line)
+}
+
+// next shows the next history entry, or the new line after the last
+func (e *editor) next() {
+	if e.browse == len(e.history.entries) {
+		return
+	}
+	e.browse++
+	if e.browse == len(e.history.entries) {
+		e.line = e.saved
+	} else {
+		e.line = []rune(e.history.entries[e.browse])
+	}
+	e.pos = len(e.line)
+}
+
+// search searches the history backwards for the query typed after
+// Ctrl-R; pressing Ctrl-R again finds an older entry. Enter submits
+// the entry found, Ctrl-G cancels the search and any other key
+// leaves the entry found on the line for editing. search reports
+// whether the entry was submitted.
+func (e *editor) search() (bool, error) {
+	var query []rune
+	match := len(e.history.entries)
+	failed := false
+
+	for {
+		found := ""
+		if match < len(e.history.entries) {
+			found = e.history.entries[match]
+		}
+		prompt := "(reverse-i-search)`" + string(query) + "': "
+		if failed {
+			prompt = "(failing " + prompt[1:]
+		}
+		e.render(prompt, []rune(found), utf8.RuneCountInString(found))
+
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return false, err
+		}
+
+		from := match
+		switch r {
+		case keyCtrlR:
+			from = match - 1
+		case keyCtrlH, keyBackspace:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+			from = len(e.history.entries) - 1
+		case keyCtrlG, keyCtrlC:
+			return false, nil
+		case keyEnter, keyLineFeed:
+			if found != "" {
+				e.line = []rune(found)
+			}
+			return true, nil
+		default:
+			if !unicode.IsPrint(r) {
+				e.in.UnreadRune()
+				if found != "" {
+					e.line = []rune(found)
+					e.pos = len(e.line)
+				}
+				return false, nil
+			}
+			query = append(query, r)
+		}
+
+		if from >= len(e.history.entries) {
+			from = len(e.history.entries) - 1
+		}
+		i := e.history.search(string(query), from)
+		failed = i < 0
+		if !failed {
+			match = i
+		}
+	}
+}
+
+// completeWord completes the word before the cursor. If there are
+// several completions it inserts their common prefix, or lists them
+// when there is nothing left to insert.
+func (e *editor) completeWord() {
+	start := e.pos
+	for start > 0 && isWordRune(e.line[start-1]) {
+		start--
+	}
+	prefix := string(e.line[start:e.pos])
+	if prefix == "" || e.complete == nil {
+		return
+	}
+
+	candidates := e.complete(prefix)
+	if len(candidates) == 0 {
+		io.WriteString(e.out, "\a")
+		return
+	}
+	common := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(prefix) {
+		e.insert([]rune(common[len(prefix):]))
+		return
+	}
+	if len(candidates) > 1 {
+		io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+	}
+}
+
+// isWordRune reports whether r can be part of an identifier
+func isWordRune(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_'
+}
+
+func (e *editor) refresh() {
+	e.render(e.prompt, e.line, e.pos)
+}
+
+// render redraws the line with the cursor at pos. A line wider than
+// the terminal scrolls horizontally to keep the cursor visible.
+func (e *editor) render(prompt string, line []rune, pos int) {
+	width := defaultWidth
+	if e.terminal != nil {
+		width = e.terminal.width()
+	}
+
+	start, end := 0, len(line)
+	available := width - utf8.RuneCountInString(prompt) - 1
+	if available > 0 {
+		if pos > available {
+			start = pos - available
+		}
+		if end-start > available {
+			end = start + available
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("\r" + prompt + string(line[start:end]) + "\x1b[K")
+	column := utf8.RuneCountInString(prompt) + pos - start
+	out.WriteString("\r")
+	if column > 0 {
+		fmt.Fprintf(&out, "\x1b[%dC", column)
+	}
+	io.WriteString(e.out, out.String())
+}
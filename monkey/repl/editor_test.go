@@ -0,0 +1,229 @@
+// Package repl repl/editor_test.go
+package repl
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditorReadLine(t *testing.T) {
+	tests := []struct {
+		keys     string
+		history  []string
+		expected []string
+	}{
+		{"let x = 1;\r", nil, []string{"let x = 1;"}},
+		{"abc\x7f\x7fd\r", nil, []string{"ad"}},
+		{"bc\x01a\x05d\r", nil, []string{"abcd"}},
+		{"ac\x1b[Db\r", nil, []string{"abc"}},
+		{"ac\x02b\x06d\r", nil, []string{"abcd"}},
+		{"abc\x1b[H\x1b[3~\x1b[Fd\r", nil, []string{"bcd"}},
+		{"abc\x1b[1~x\x1b[4~y\r", nil, []string{"xabcy"}},
+		{"abcd\x02\x02\x0b\r", nil, []string{"ab"}},
+		{"abcd\x02\x02\x15\r", nil, []string{"cd"}},
+		{"let foo = 1\x17bar\r", nil, []string{"let foo = bar"}},
+		{"ab\x01\x04\r", nil, []string{"b"}},
+		{"\x1b[A\r", []string{"one", "two"}, []string{"two"}},
+		{"\x1b[A\x1b[A\x1b[A\r", []string{"one", "two"}, []string{"one"}},
+		{"new\x10\x10\x0e\x0e\r", []string{"one", "two"}, []string{"new"}},
+		{"\x1b[A!\r\x1b[A\r", []string{"one"}, []string{"one!", "one!"}},
+		{"\x12on\r", []string{"one", "two", "none"}, []string{"none"}},
+		{"\x12on\x12\r", []string{"one", "two", "none"}, []string{"one"}},
+		{"\x12on\x12\x12\r", []string{"one", "two", "none"}, []string{"one"}},
+		{"\x12tw\x1b[D!\r", []string{"one", "two"}, []string{"tw!o"}},
+		{"x\x12tw\x07\r", []string{"one", "two"}, []string{"x"}},
+		{"x\x12zz\r", []string{"one", "two"}, []string{"x"}},
+		{"\x12twx\x7f\x7f\x7fn\r", []string{"one", "two"}, []string{"one"}},
+		{"pu\t(1)\r", nil, []string{"puts(1)"}},
+		{"re\t\r", nil, []string{"re"}},
+		{"ret\t 1\r", nil, []string{"return 1"}},
+		{"abc\x03def\r", nil, []string{"", "def"}},
+		{"fÿ\x7f\r", nil, []string{"f"}},
+	}
+
+	complete := func(prefix string) []string {
+		var completions []string
+		for _, name := range []string{"puts", "rest", "return"} {
+			if strings.HasPrefix(name, prefix) {
+				completions = append(completions, name)
+			}
+		}
+		return completions
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		h := &history{entries: tt.history}
+		e := newEditor(strings.NewReader(tt.keys), &out, nil, h, complete)
+
+		lines := []string{}
+		for {
+			line, err := e.readLine(PROMPT)
+			if err == io.EOF {
+				break
+			}
+			if err != nil && err != errInterrupt {
+				t.Fatalf("readLine error for %q: %s", tt.keys, err)
+			}
+			lines = append(lines, line)
+		}
+		if !reflect.DeepEqual(lines, tt.expected) {
+			t.Errorf("wrong lines for %q. want=%q, got=%q",
+				tt.keys, tt.expected, lines)
+		}
+	}
+}
+
+func TestEditorInterruptAndEOF(t *testing.T) {
+	var out bytes.Buffer
+	e := newEditor(strings.NewReader("abc\x03\x04"), &out, nil, &history{}, nil)
+
+	_, err := e.readLine(PROMPT)
+	if err != errInterrupt {
+		t.Errorf("expected errInterrupt, got %v", err)
+	}
+	_, err = e.readLine(PROMPT)
+	if err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+	if !strings.Contains(out.String(), "^C\r\n") {
+		t.Errorf("interrupt not echoed. got=%q", out.String())
+	}
+}
+
+func TestEditorListsCompletions(t *testing.T) {
+	var out bytes.Buffer
+	complete := func(prefix string) []string { return []string{"rest", "return"} }
+	e := newEditor(strings.NewReader("re\t\r"), &out, nil, &history{}, complete)
+
+	_, err := e.readLine(PROMPT)
+	if err != nil {
+		t.Fatalf("readLine error: %s", err)
+	}
+	if !strings.Contains(out.String(), "\r\nrest  return\r\n") {
+		t.Errorf("completions not listed. got=%q", out.String())
+	}
+}
+
+func TestEditorScrollsLongLines(t *testing.T) {
+	var out bytes.Buffer
+	line := strings.Repeat("x", 100) + "end"
+	e := newEditor(strings.NewReader(line+"\r"), &out, nil, &history{}, nil)
+
+	got, err := e.readLine(PROMPT)
+	if err != nil {
+		t.Fatalf("readLine error: %s", err)
+	}
+	if got != line {
+		t.Errorf("wrong line. want=%q, got=%q", line, got)
+	}
+	last := out.String()[strings.LastIndex(out.String(), "\r>> "):]
+	if !strings.Contains(last, "xend\x1b[K") || len(last) > defaultWidth+20 {
+		t.Errorf("line not scrolled. got=%q", last)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, HISTORY_FILE)
+
+	h := loadHistory(file)
+	if len(h.entries) != 0 {
+		t.Fatalf("expected empty history, got %q", h.entries)
+	}
+	for _, line := range []string{"one", "two", "two", "  ", "three"} {
+		h.add(line)
+	}
+
+	expected := []string{"one", "two", "three"}
+	if !reflect.DeepEqual(h.entries, expected) {
+		t.Errorf("wrong entries. want=%q, got=%q", expected, h.entries)
+	}
+	reloaded := loadHistory(file)
+	if !reflect.DeepEqual(reloaded.entries, expected) {
+		t.Errorf("wrong reloaded entries. want=%q, got=%q", expected, reloaded.entries)
+	}
+}
+
+func TestHistoryFileIsTrimmed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, HISTORY_FILE)
+
+	var lines []string
+	for i := 0; i < maxHistory+10; i++ {
+		lines = append(lines, strings.Repeat("x", i+1))
+	}
+	err = ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := loadHistory(file)
+	if len(h.entries) != maxHistory || h.entries[0] != lines[10] {
+		t.Fatalf("history not trimmed. got %d entries", len(h.entries))
+	}
+	reloaded := loadHistory(file)
+	if !reflect.DeepEqual(reloaded.entries, h.entries) {
+		t.Errorf("history file not trimmed. got %d entries", len(reloaded.entries))
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	tests := []struct {
+		input    string
+		prefix   string
+		expected []string
+	}{
+		{"", "re", []string{"rest", "return"}},
+		{"", "f", []string{"false", "first", "fn", "for"}},
+		{"let first_name = 1;", "fi", []string{"first", "first_name"}},
+		{"let len = 1;", "le", []string{"len", "let"}},
+		{":engine eval\nlet falsy = 0;", "fa", []string{"false", "falsy"}},
+		{"", "zz", []string{}},
+		{"for (x in [1]) { match (x) { _ => x } }", "$", []string{}},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		s := newSession(&out)
+		for _, line := range strings.Split(tt.input, "\n") {
+			if isCommand(line) {
+				s.command(line)
+				continue
+			}
+			s.run(parseProgram(t, line), "")
+		}
+
+		got := s.completions(tt.prefix)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("wrong completions of %q. want=%q, got=%q",
+				tt.prefix, tt.expected, got)
+		}
+	}
+}
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %q", input, p.Errors())
+	}
+	return program
+}
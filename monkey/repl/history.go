@@ -0,0 +1,94 @@
+// Package repl repl/history.go
+package repl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HISTORY_FILE in the home directory that keeps the entered lines
+const HISTORY_FILE = ".monkey_history"
+
+// maxHistory is the number of lines kept in the history
+const maxHistory = 1000
+
+// history of the entered lines, oldest first
+type history struct {
+	entries []string
+	// file the lines are appended to, none if empty
+	file string
+}
+
+// historyPath returns the path of the history file, or the empty
+// string if there is no home directory
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, HISTORY_FILE)
+}
+
+// loadHistory reads the history in file, which need not exist
+func loadHistory(file string) *history {
+	h := &history{file: file}
+	if file == "" {
+		return h
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return h
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+		// the file is only appended to, so trim it here
+		data := strings.Join(h.entries, "\n") + "\n"
+		ioutil.WriteFile(file, []byte(data), 0600)
+	}
+	return h
+}
+
+// add appends line to the history unless it is blank or repeats
+// the last line. The history file is best effort, so write errors
+// are ignored.
+func (h *history) add(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == line {
+		return
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[1:]
+	}
+
+	if h.file == "" {
+		return
+	}
+	f, err := os.OpenFile(h.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// search returns the index of the newest entry at or before from
+// that contains query, or -1 if there is none
+func (h *history) search(query string, from int) int {
+	for i := from; i >= 0; i-- {
+		if strings.Contains(h.entries[i], query) {
+			return i
+		}
+	}
+	return -1
+}
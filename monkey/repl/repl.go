@@ -5,67 +5,275 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
 	"monkey/compiler"
+	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/token"
 	"monkey/vm"
+	"sort"
+	"strings"
+	"time"
 )
 
 // PROMPT for command line
 const PROMPT = ">> "
 
-// Start to run REPL
+// CONTINUATION_PROMPT for the further lines of an unfinished entry
+const CONTINUATION_PROMPT = ".. "
+
+// names of the engines that run the entries
+const (
+	engineVM   = "vm"
+	engineEval = "eval"
+)
+
+// Start to run REPL. On a terminal lines are read with a line
+// editor that keeps a history in HISTORY_FILE, otherwise as
+// plain lines.
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	s := newSession(out)
 
-	constants := []object.Object{}
-	globals := make([]object.Object, vm.GlobalSize)
-	symbolTable := compiler.NewSymbolTable()
-	for i, v := range object.Builtins {
-		symbolTable.DefineBuiltin(i, v.Name)
+	var lines lineReader = &scanReader{scanner: bufio.NewScanner(in), out: out}
+	if t, ok := openTerminal(in, out); ok {
+		lines = newEditor(in, out, t, loadHistory(historyPath()), s.completions)
 	}
+	s.context.Stdin = &linesInput{lines: lines}
 
 	for {
-		fmt.Fprintf(out, PROMPT)
-
-		scanned := scanner.Scan()
-
-		if !scanned {
+		input, ok := readEntry(lines)
+		if !ok {
 			return
 		}
 
-		line := scanner.Text()
+		if isCommand(input) {
+			s.command(input)
+			continue
+		}
 
-		l := lexer.New(line)
-		p := parser.New(l)
+		p := parser.New(lexer.New(input))
 		program := p.ParseProgram()
-
 		if len(p.Errors()) != 0 {
 			printParserErrors(out, p.Errors())
 			continue
 		}
+		s.run(program, "")
+	}
+}
 
-		comp := compiler.NewWithState(symbolTable, constants)
-		err := comp.Compile(program)
-		if err != nil {
-			fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
-			continue
+// session holds the state shared by the entries of a REPL
+type session struct {
+	out    io.Writer
+	engine string
+	timing bool
+	// context of the builtins, which write to out
+	context *object.Context
+
+	constants   []object.Object
+	globals     []object.Object
+	symbolTable *compiler.SymbolTable
+	macroEnv    *object.Environment
+	env         *object.Environment
+}
+
+func newSession(out io.Writer) *session {
+	s := &session{
+		out:     out,
+		engine:  engineVM,
+		context: &object.Context{Stdout: out, Stderr: out},
+	}
+	s.reset()
+	return s
+}
+
+// reset forgets all definitions of both engines
+func (s *session) reset() {
+	s.constants = []object.Object{}
+	s.globals = make([]object.Object, vm.GlobalSize)
+	s.symbolTable = compiler.NewSymbolTable()
+	s.symbolTable.DefineBuiltins(object.StandardRegistry)
+	s.macroEnv = object.NewEnvironment()
+	s.env = object.NewEnvironment()
+	s.env.SetContext(s.context)
+}
+
+// run expands the macros of program and runs it with the current
+// engine, printing its value. file names the source in errors.
+func (s *session) run(program *ast.Program, file string) {
+	evaluator.DefineMacros(program, s.macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, s.macroEnv)
+	if err != nil {
+		fmt.Fprintf(s.out, "Woops! Macro expansion failed:\n %s\n", err)
+		return
+	}
+
+	start := time.Now()
+	var result object.Object
+	if s.engine == engineEval {
+		result = s.eval(expanded.(*ast.Program), file)
+	} else {
+		result = s.execute(expanded.(*ast.Program), file)
+	}
+	elapsed := time.Since(start)
+
+	// result is nil if nothing was evaluated, e.g. only macros
+	// were defined, or if an error has been reported
+	if result != nil {
+		io.WriteString(s.out, result.Inspect())
+		io.WriteString(s.out, "\n")
+	}
+	if s.timing {
+		fmt.Fprintf(s.out, "(%s)\n", elapsed)
+	}
+}
+
+// completions returns the sorted keywords, builtins and globals of
+// the current engine that start with prefix
+func (s *session) completions(prefix string) []string {
+	names := append(token.Keywords(), object.StandardRegistry.Names()...)
+	if s.engine == engineEval {
+		names = append(names, s.env.Names()...)
+	} else {
+		for _, symbol := range s.symbolTable.Symbols(compiler.GlobalScope) {
+			if !isHidden(symbol.Name) {
+				names = append(names, symbol.Name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	completions := []string{}
+	for i, name := range names {
+		if strings.HasPrefix(name, prefix) && (i == 0 || names[i-1] != name) {
+			completions = append(completions, name)
+		}
+	}
+	return completions
+}
+
+// execute compiles program and runs it in the VM
+func (s *session) execute(program *ast.Program, file string) object.Object {
+	comp := compiler.NewWithState(s.symbolTable, s.constants)
+	comp.SetFile(file)
+	err := comp.Compile(program)
+	if err != nil {
+		fmt.Fprintf(s.out, "Woops! Compilation failed:\n %s\n", err)
+		return nil
+	}
+
+	code := comp.Bytecode()
+	s.constants = code.Constants
+	machine := vm.NewWithGlobalsStore(code, s.globals)
+	machine.SetContext(s.context)
+
+	err = machine.Run()
+	if err != nil {
+		if runtimeErr, ok := err.(*object.RuntimeError); ok {
+			fmt.Fprintf(s.out, "Woops! Executing bytecode failed.\n%s\n",
+				runtimeErr.Traceback())
+			return nil
+		}
+		fmt.Fprintf(s.out, "Woops! Executing bytecode failed.\n %s\n", err)
+		return nil
+	}
+	return machine.LastPoppedStackElem()
+}
+
+// eval runs program in the evaluator
+func (s *session) eval(program *ast.Program, file string) object.Object {
+	result := evaluator.Eval(program, s.env)
+	if errObj, ok := result.(*object.Error); ok {
+		for i := range errObj.Stack {
+			errObj.Stack[i].Location.File = file
 		}
+		fmt.Fprintf(s.out, "Woops! Evaluation failed.\n%s\n", errObj.Traceback())
+		return nil
+	}
+	return result
+}
 
-		code := comp.Bytecode()
-		constants = code.Constants
-		machine := vm.NewWithGlobalsStore(code, globals)
+// linesInput lets builtins such as gets read the lines that
+// follow an entry
+type linesInput struct {
+	lines lineReader
+	buf   []byte
+}
 
-		err = machine.Run()
+func (r *linesInput) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		line, err := r.lines.readLine("")
 		if err != nil {
-			fmt.Fprintf(out, "Woops! Executing bytecode failed.\n %s\n", err)
-			continue
+			return 0, io.EOF
+		}
+		r.buf = []byte(line + "\n")
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// readEntry reads the next entry. An entry continues over several
+// lines while brackets or a block comment are open, or while the
+// parser runs out of input; a blank line ends the latter. Commands
+// are a single line. It reports false when the input is exhausted.
+func readEntry(lines lineReader) (string, bool) {
+	var input strings.Builder
+	prompt := PROMPT
+	for {
+		line, err := lines.readLine(prompt)
+		if err == errInterrupt {
+			// the entry is abandoned
+			return "", true
+		}
+		if err != nil {
+			// the input may end within an entry, which is run
+			// so that what is wrong with it is reported
+			return input.String(), input.Len() != 0
+		}
+
+		if input.Len() == 0 && isCommand(line) {
+			return line, true
+		}
+		if input.Len() != 0 {
+			input.WriteString("\n")
+		}
+		input.WriteString(line)
+
+		blank := strings.TrimSpace(line) == ""
+		if !isOpen(input.String()) && (blank || !unexpectedEOF(input.String())) {
+			return input.String(), true
+		}
+		prompt = CONTINUATION_PROMPT
+	}
+}
+
+// isOpen reports whether input has unclosed brackets or an
+// unterminated block comment
+func isOpen(input string) bool {
+	depth := 0
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		case token.ILLEGAL:
+			if strings.HasPrefix(tok.Literal, "/*") {
+				return true
+			}
 		}
-		lastPopped := machine.LastPoppedStackElem()
-		io.WriteString(out, lastPopped.Inspect())
-		io.WriteString(out, "\n")
 	}
+	return depth > 0
+}
+
+// unexpectedEOF reports whether the parser runs out of input
+func unexpectedEOF(input string) bool {
+	p := parser.New(lexer.New(input))
+	p.ParseProgram()
+	return p.UnexpectedEOF()
 }
 
 func printParserErrors(out io.Writer, errors []string) {
@@ -0,0 +1,167 @@
+// Package repl repl/repl_test.go
+package repl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMultiLineInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2\n", ">> 3\n>> "},
+		{
+			"let add = fn(a, b) {\n  a + b\n};\nadd(1, 2)\n",
+			">> .. .. Closure[",
+		},
+		{
+			"add(1,\n2)\n",
+			">> .. Woops! Compilation failed:\n 1:1: undefined variable add\n",
+		},
+		{"let add = fn(a, b) {\n  a + b\n};\nadd(1,\n2)\n", ".. 3\n>> "},
+		{"[1,\n\n2]\n", ">> .. .. [1, 2]\n>> "},
+		{"(1 +\n2) * 3\n", ">> .. 9\n>> "},
+		{"let x =\n5;\nx\n", ">> .. 5\n>> 5\n>> "},
+		{"let x =\n\nx\n", ">> .. \t2:1: no prefix parse function for EOF found\n>> "},
+		{"/* a\ncomment */ 7\n", ">> .. 7\n>> "},
+		{"let s = \"{\"; s\n", ">> {\n>> "},
+		{"1 + 2)\n", ">> \t1:6: no prefix parse function for ) found\n"},
+		{"1 +", ">> .. \t1:4: no prefix parse function for EOF found\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+		if !strings.Contains(out.String(), tt.expected) {
+			t.Errorf("wrong output for %q.\nwant to contain=%q\ngot=%q",
+				tt.input, tt.expected, out.String())
+		}
+	}
+}
+
+func TestMultiLineInputKeepsState(t *testing.T) {
+	input := `let counter = fn() {
+	let n = 0;
+	fn() {
+		n += 1;
+		n
+	}
+};
+let next = counter();
+next();
+next()
+`
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	if !strings.HasSuffix(out.String(), "1\n>> 2\n>> ") {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "lib.mk")
+	err = ioutil.WriteFile(script, []byte("let triple = fn(x) { x * 3 };\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := filepath.Join(dir, "fail.mk")
+	err = ioutil.WriteFile(failing, []byte("let x = 1;\nx / 0;\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			":ast -a + b\n",
+			[]string{
+				"Program 1:1\n" +
+					"  ExpressionStatement 1:1\n" +
+					"    InfixExpression 1:4 +\n" +
+					"      PrefixExpression 1:1 -\n" +
+					"        Identifier 1:2 a\n" +
+					"      Identifier 1:6 b\n",
+			},
+		},
+		{":ast let = 1\n", []string{"\t1:5: expected next token to be 'IDENT'"}},
+		{":ast\n", []string{"usage: :ast <expr>"}},
+		{
+			"let one = 1;\n:bytecode one + 2\n",
+			[]string{
+				"<main>:\n" +
+					"0000 OpGetGlobal 0\n" +
+					"0003 OpConstant 1\n" +
+					"0006 OpAdd\n" +
+					"0007 OpPop\n",
+				"0001 INTEGER 2\n",
+			},
+		},
+		{
+			":bytecode let two = 2\ntwo\n",
+			[]string{"0003 OpSetGlobal 0", "undefined variable two"},
+		},
+		{
+			"let a = 1;\nlet b = \"two\";\n:globals\n",
+			[]string{"a = 1\nb = two\n"},
+		},
+		{
+			"for (x in [1]) { match (x) { _ => x } }\n:globals\n",
+			[]string{"]\n>> x = 1\n>> "},
+		},
+		{
+			":engine eval\nlet a = [1];\n:globals\n",
+			[]string{"engine: eval\n", "a = [1]\n"},
+		},
+		{":load " + script + "\ntriple(2)\n", []string{">> 6\n"}},
+		{
+			":engine eval\n:load " + script + "\ntriple(3)\n",
+			[]string{">> 9\n"},
+		},
+		{":load " + failing + "\n", []string{failing + "\", line 2, column 3"}},
+		{
+			":engine eval\n:load " + failing + "\n",
+			[]string{"Woops! Evaluation failed.", failing + "\", line 2, column 3"},
+		},
+		{":load " + filepath.Join(dir, "missing.mk") + "\n", []string{"Woops! open "}},
+		{
+			"let a = 1;\n:reset\na\n:globals\n",
+			[]string{"undefined variable a\n>> >> "},
+		},
+		{":engine\n", []string{"engine: vm\n"}},
+		{":engine eval\n1 / 0\n", []string{"Woops! Evaluation failed."}},
+		{":engine jit\n", []string{`unknown engine "jit", use vm or eval`}},
+		{":time\n1 + 1\n", []string{"timing on\n", ">> 2\n("}},
+		{":time\n:time\n1 + 1\n", []string{"timing off\n>> 2\n>> "}},
+		{":quit\n", []string{"unknown command :quit, try :help"}},
+		{":help\n", []string{":globals"}},
+		{"puts(gets())\nmonkey\n", []string{">> monkey\nnull\n>> "}},
+		{
+			":engine eval\nputs(gets() + \"!\")\nmonkey\n",
+			[]string{">> monkey!\nnull\n>> "},
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+		for _, expected := range tt.expected {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("wrong output for %q.\nwant to contain=%q\ngot=%q",
+					tt.input, expected, out.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,9 @@
+// Package repl repl/term_linux.go
+package repl
+
+import "syscall"
+
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
@@ -0,0 +1,26 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+// Package repl repl/term_other.go
+package repl
+
+import (
+	"errors"
+	"io"
+)
+
+// terminal is not supported on this platform, so the REPL
+// always reads plain lines
+type terminal struct{}
+
+func openTerminal(in io.Reader, out io.Writer) (*terminal, bool) {
+	return nil, false
+}
+
+func (t *terminal) makeRaw() (func(), error) {
+	return nil, errors.New("raw terminal mode not supported")
+}
+
+func (t *terminal) width() int {
+	return defaultWidth
+}
@@ -0,0 +1,86 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+// Package repl repl/term_unix.go
+package repl
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminal is a terminal device that the editor switches
+// into raw mode while it reads a line
+type terminal struct {
+	fd uintptr
+}
+
+// openTerminal returns the terminal if both in and out are one
+func openTerminal(in io.Reader, out io.Writer) (*terminal, bool) {
+	inFile, ok := in.(*os.File)
+	if !ok || !isTerminal(inFile.Fd()) {
+		return nil, false
+	}
+	outFile, ok := out.(*os.File)
+	if !ok || !isTerminal(outFile.Fd()) {
+		return nil, false
+	}
+	return &terminal{fd: inFile.Fd()}, true
+}
+
+func isTerminal(fd uintptr) bool {
+	var termios syscall.Termios
+	return ioctl(fd, ioctlGetTermios, unsafe.Pointer(&termios)) == nil
+}
+
+// makeRaw switches the terminal into raw mode, in which input is
+// read key by key without echo, and returns a func that restores
+// the previous mode
+func (t *terminal) makeRaw() (func(), error) {
+	var old syscall.Termios
+	err := ioctl(t.fd, ioctlGetTermios, unsafe.Pointer(&old))
+	if err != nil {
+		return nil, err
+	}
+
+	raw := old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK |
+		syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON |
+		syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	err = ioctl(t.fd, ioctlSetTermios, unsafe.Pointer(&raw))
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		ioctl(t.fd, ioctlSetTermios, unsafe.Pointer(&old))
+	}, nil
+}
+
+// width returns the number of columns of the terminal
+func (t *terminal) width() int {
+	var size struct {
+		rows, cols, xpixels, ypixels uint16
+	}
+	err := ioctl(t.fd, syscall.TIOCGWINSZ, unsafe.Pointer(&size))
+	if err != nil || size.cols == 0 {
+		return defaultWidth
+	}
+	return int(size.cols)
+}
+
+func ioctl(fd, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
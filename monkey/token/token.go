@@ -1,14 +1,73 @@
 // Package token token/token.go
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 // TokenType type
 type TokenType string
 
+// Position of a token in the source input.
+// Line and Column are 1-based, Offset is a 0-based byte offset.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// IsValid reports whether the position has been set
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position as line:col
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Advance returns the position reached after reading text
+// starting at p
+func (p Position) Advance(text string) Position {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			p.Line++
+			p.Column = 0
+		}
+		p.Column++
+		p.Offset++
+	}
+	return p
+}
+
+// Span is a range of source text from Start up to,
+// but not including, End
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// String returns the span as line:col-line:col
+func (s Span) String() string {
+	return fmt.Sprintf("%s-%s", s.Start, s.End)
+}
+
 // Token struct
 type Token struct {
 	Type    TokenType
 	Literal string
-	Col     int
+	Line    int
+	Column  int
+	Offset  int
+}
+
+// Pos returns the position of the token
+func (t Token) Pos() Position {
+	return Position{Line: t.Line, Column: t.Column, Offset: t.Offset}
 }
 
 const (
@@ -21,8 +80,12 @@ const (
 	IDENT = "IDENT"
 	// INT datatype
 	INT = "INT"
+	// FLOAT datatype
+	FLOAT = "FLOAT"
 	// STRING datatype
 	STRING = "STRING"
+	// COMMENT line or block comment
+	COMMENT = "COMMENT"
 
 	// ASSIGN Operators
 	ASSIGN = "="
@@ -34,6 +97,22 @@ const (
 	SLASH = "/"
 	// ASTERISK Operator
 	ASTERISK = "*"
+	// PERCENT Operator
+	PERCENT = "%"
+	// AND Operator
+	AND = "&&"
+	// OR Operator
+	OR = "||"
+	// PLUS_ASSIGN Operator
+	PLUS_ASSIGN = "+="
+	// MINUS_ASSIGN Operator
+	MINUS_ASSIGN = "-="
+	// ASTERISK_ASSIGN Operator
+	ASTERISK_ASSIGN = "*="
+	// SLASH_ASSIGN Operator
+	SLASH_ASSIGN = "/="
+	// PERCENT_ASSIGN Operator
+	PERCENT_ASSIGN = "%="
 
 	// BANG Delimiters
 	BANG = "!"
@@ -41,6 +120,8 @@ const (
 	COMMA = ","
 	// COLON Delimiters
 	COLON = ":"
+	// ARROW Delimiter
+	ARROW = "=>"
 	// SEMICOLON Delimiter
 	SEMICOLON = ";"
 	// LPAREN Delimiter
@@ -63,6 +144,10 @@ const (
 	EQ = "=="
 	// NOT_EQ Delimiter
 	NOT_EQ = "!="
+	// LT_EQ Operator
+	LT_EQ = "<="
+	// GT_EQ Operator
+	GT_EQ = ">="
 
 	// FUNCTION Keyword
 	FUNCTION = "FUNCTION"
@@ -78,6 +163,20 @@ const (
 	TRUE = "TRUE"
 	// FALSE Keyword
 	FALSE = "FALSE"
+	// WHILE Keyword
+	WHILE = "WHILE"
+	// FOR Keyword
+	FOR = "FOR"
+	// IN Keyword
+	IN = "IN"
+	// BREAK Keyword
+	BREAK = "BREAK"
+	// CONTINUE Keyword
+	CONTINUE = "CONTINUE"
+	// MATCH Keyword
+	MATCH = "MATCH"
+	// MACRO Keyword
+	MACRO = "MACRO"
 )
 
 var keywords = map[string]TokenType{
@@ -88,6 +187,24 @@ var keywords = map[string]TokenType{
 	"return": RETURN,
 	"true":   TRUE,
 	"false":  FALSE,
+
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"match":    MATCH,
+	"macro":    MACRO,
+}
+
+// Keywords returns the sorted keywords of the language
+func Keywords() []string {
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // LookupIdent returns Ident or Keyword
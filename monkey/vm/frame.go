@@ -27,3 +27,16 @@ func NewFrame(cl *object.Closure, basePointer int) *Frame {
 func (f *Frame) Instructions() code.Instructions {
 	return f.cl.Fn.Instructions
 }
+
+// Location returns the source location of the current instruction
+func (f *Frame) Location() (code.Location, bool) {
+	return f.cl.Fn.SourceMap.Location(f.ip)
+}
+
+// Name returns the name of the function executing in the frame
+func (f *Frame) Name() string {
+	if f.cl.Fn.Name == "" {
+		return object.AnonymousFunctionName
+	}
+	return f.cl.Fn.Name
+}
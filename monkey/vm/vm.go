@@ -2,30 +2,38 @@
 package vm
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"monkey/code"
 	"monkey/compiler"
 	"monkey/object"
 )
 
-// StackSize const
+// StackSize is the initial size of the stack, which grows as
+// needed
 const StackSize = 2048
 
-// MaxFrames const
-const MaxFrames = 1024
+// initialFrames is the initial size of the frame stack, which grows
+// up to the maximum call depth of the limits
+const initialFrames = 64
 
 // True var
-var True = &object.Boolean{Value: true}
+var True = object.TRUE
 
 // False var
-var False = &object.Boolean{Value: false}
+var False = object.FALSE
 
 // Null var
-var Null = &object.Null{}
+var Null = object.NULL
 
 // GlobalSize const
 const GlobalSize = 65536
 
+// Tracer is called with the location of every instruction
+// before it is executed
+type Tracer func(op code.Opcode, location code.Location)
+
 // VM struct
 type VM struct {
 	constants []object.Object
@@ -36,17 +44,26 @@ type VM struct {
 	globals     []object.Object
 	frames      []*Frame
 	framesIndex int
+	tracer      Tracer
+	context     *object.Context
+	builtins    *object.Registry
+	limits      object.Limits
+	// meter counts the instructions of a run, nil if
+	// the run has no limits
+	meter *object.Meter
 }
 
 // New func
 func New(bytecode *compiler.Bytecode) *VM {
 	mainFn := &object.CompiledFunction{
+		Name:         object.MainFunctionName,
 		Instructions: bytecode.Instructions,
+		SourceMap:    bytecode.SourceMap,
 	}
 	mainClosure := &object.Closure{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 
-	frames := make([]*Frame, MaxFrames)
+	frames := make([]*Frame, initialFrames)
 
 	frames[0] = mainFrame
 
@@ -57,6 +74,8 @@ func New(bytecode *compiler.Bytecode) *VM {
 		globals:     make([]object.Object, GlobalSize),
 		frames:      frames,
 		framesIndex: 1,
+		context:     object.StandardContext,
+		builtins:    object.StandardRegistry,
 	}
 }
 
@@ -70,6 +89,35 @@ func NewWithGlobalsStore(
 	return vm
 }
 
+// SetTracer installs a tracer, or removes it when t is nil
+func (vm *VM) SetTracer(t Tracer) {
+	vm.tracer = t
+}
+
+// SetContext sets the context that builtins run in, or restores
+// object.StandardContext when ctx is nil
+func (vm *VM) SetContext(ctx *object.Context) {
+	if ctx == nil {
+		ctx = object.StandardContext
+	}
+	vm.context = ctx
+}
+
+// SetBuiltins sets the builtins that OpGetBuiltin indexes, which
+// must be those the program was compiled with, or restores
+// object.StandardRegistry when r is nil
+func (vm *VM) SetBuiltins(r *object.Registry) {
+	if r == nil {
+		r = object.StandardRegistry
+	}
+	vm.builtins = r
+}
+
+// SetLimits sets the limits of the following runs
+func (vm *VM) SetLimits(limits object.Limits) {
+	vm.limits = limits
+}
+
 // LastPoppedStackElem func
 func (vm *VM) LastPoppedStackElem() object.Object {
 	return vm.stack[vm.sp]
@@ -77,6 +125,84 @@ func (vm *VM) LastPoppedStackElem() object.Object {
 
 // Run func
 func (vm *VM) Run() error {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext runs the program until it ends, fails with an
+// *object.RuntimeError, or is stopped with an *object.AbortError
+// when it exceeds the limits set with SetLimits or ctx is done
+func (vm *VM) RunContext(ctx context.Context) error {
+	vm.meter = nil
+	if vm.limits != (object.Limits{}) || ctx.Done() != nil {
+		vm.meter = object.NewMeter(ctx, vm.limits)
+	}
+
+	err := vm.run()
+	if err != nil {
+		if abortErr, ok := err.(*object.AbortError); ok {
+			abortErr.Stack = vm.stackTrace()
+			return abortErr
+		}
+		return &object.RuntimeError{Err: err, Stack: vm.stackTrace()}
+	}
+	return nil
+}
+
+// Call calls fn, a closure or builtin, with args and returns its
+// result. The call runs on the constants and globals of vm, so it can
+// call a function defined by a program that vm, or a VM sharing its
+// globals, has run before.
+func (vm *VM) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	return vm.CallContext(context.Background(), fn, args...)
+}
+
+// CallContext calls fn as Call does, and stops as RunContext does
+func (vm *VM) CallContext(
+	ctx context.Context,
+	fn object.Object,
+	args ...object.Object,
+) (object.Object, error) {
+	if len(args) > math.MaxUint8 {
+		return nil, fmt.Errorf("too many arguments: %d", len(args))
+	}
+	// a main function that calls what is on the stack
+	ins := code.Make(code.OpCall, len(args))
+	ins = append(ins, code.Make(code.OpPop)...)
+	mainFn := &object.CompiledFunction{
+		Name:         object.MainFunctionName,
+		Instructions: ins,
+	}
+	vm.frames[0] = NewFrame(&object.Closure{Fn: mainFn}, 0)
+	vm.framesIndex = 1
+
+	vm.sp = 0
+	vm.push(fn)
+	for _, arg := range args {
+		vm.push(arg)
+	}
+
+	err := vm.RunContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return vm.LastPoppedStackElem(), nil
+}
+
+// stackTrace walks the active frames, innermost first
+func (vm *VM) stackTrace() []object.StackFrame {
+	stack := make([]object.StackFrame, 0, vm.framesIndex)
+	for i := vm.framesIndex - 1; i >= 0; i-- {
+		frame := vm.frames[i]
+		location, _ := frame.Location()
+		stack = append(stack, object.StackFrame{
+			Function: frame.Name(),
+			Location: location,
+		})
+	}
+	return stack
+}
+
+func (vm *VM) run() error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
@@ -87,6 +213,16 @@ func (vm *VM) Run() error {
 		ins = vm.currentFrame().Instructions()
 		op = code.Opcode(ins[ip])
 
+		if vm.tracer != nil {
+			location, _ := vm.currentFrame().Location()
+			vm.tracer(op, location)
+		}
+		if vm.meter != nil {
+			if err := vm.meter.Step(); err != nil {
+				return err
+			}
+		}
+
 		switch op {
 		case code.OpConstant:
 			constIndex := code.ReadUint16(ins[ip+1:])
@@ -100,7 +236,7 @@ func (vm *VM) Run() error {
 		case code.OpPop:
 			vm.pop()
 
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
 				return err
@@ -118,7 +254,8 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan,
+			code.OpLessThan, code.OpLessThanOrEqual, code.OpGreaterThanOrEqual:
 			err := vm.executeComparison(op)
 			if err != nil {
 				return err
@@ -149,6 +286,55 @@ func (vm *VM) Run() error {
 				vm.currentFrame().ip = pos - 1
 			}
 
+		case code.OpJumpNotTruthyOrPop:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if !isTruthy(vm.stack[vm.sp-1]) {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpJumpTruthyOrPop:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if isTruthy(vm.stack[vm.sp-1]) {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpIterator:
+			collection := vm.pop()
+			iterator, ok := object.NewIterator(collection)
+			if !ok {
+				return fmt.Errorf("cannot iterate over %s", collection.Type())
+			}
+			err := vm.push(iterator)
+			if err != nil {
+				return err
+			}
+
+		case code.OpIteratorNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			iterator, ok := vm.pop().(*object.Iterator)
+			if !ok {
+				return fmt.Errorf("not an iterator: %s", vm.stack[vm.sp].Type())
+			}
+			element, ok := iterator.Next()
+			if !ok {
+				vm.currentFrame().ip = pos - 1
+				break
+			}
+			err := vm.push(element)
+			if err != nil {
+				return err
+			}
+
 		case code.OpNull:
 			err := vm.push(Null)
 			if err != nil {
@@ -164,7 +350,7 @@ func (vm *VM) Run() error {
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
 
-			err := vm.push(vm.globals[globalIndex])
+			err := vm.pushVariable(vm.globals[globalIndex])
 
 			if err != nil {
 				return err
@@ -174,14 +360,34 @@ func (vm *VM) Run() error {
 			localIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip++
 			frame := vm.currentFrame()
-			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+			slot := frame.basePointer + int(localIndex)
+			if cell, ok := vm.stack[slot].(*object.Cell); ok {
+				cell.Value = vm.pop()
+			} else {
+				vm.stack[slot] = vm.pop()
+			}
 
 		case code.OpGetLocal:
 			localIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip++
 			frame := vm.currentFrame()
-			err := vm.push(vm.stack[frame.basePointer+int(localIndex)])
+			err := vm.pushVariable(deref(vm.stack[frame.basePointer+int(localIndex)]))
+
+			if err != nil {
+				return err
+			}
 
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			frame := vm.currentFrame()
+			slot := frame.basePointer + int(localIndex)
+			cell, ok := vm.stack[slot].(*object.Cell)
+			if !ok {
+				cell = &object.Cell{Value: vm.stack[slot]}
+				vm.stack[slot] = cell
+			}
+			err := vm.push(cell)
 			if err != nil {
 				return err
 			}
@@ -190,19 +396,42 @@ func (vm *VM) Run() error {
 			freeIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip++
 			currentClosure := vm.currentFrame().cl
-			err := vm.push(currentClosure.Free[freeIndex])
+			err := vm.pushVariable(deref(currentClosure.Free[freeIndex]))
 
 			if err != nil {
 				return err
 			}
 
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure.Free[freeIndex])
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			currentClosure := vm.currentFrame().cl
+			cell, ok := currentClosure.Free[freeIndex].(*object.Cell)
+			if !ok {
+				return fmt.Errorf("free variable %d is not assignable", freeIndex)
+			}
+			cell.Value = vm.pop()
+
 		case code.OpArray:
 			numElements := int(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
 			array := vm.buildArray(vm.sp-numElements, vm.sp)
 			vm.sp = vm.sp - numElements
+			err := vm.allocate(object.SizeOf(array))
+			if err != nil {
+				return err
+			}
 
-			err := vm.push(array)
+			err = vm.push(array)
 			if err != nil {
 				return err
 			}
@@ -215,6 +444,10 @@ func (vm *VM) Run() error {
 				return err
 			}
 			vm.sp = vm.sp - numElements
+			err = vm.allocate(object.SizeOf(hash))
+			if err != nil {
+				return err
+			}
 			err = vm.push(hash)
 			if err != nil {
 				return err
@@ -228,6 +461,45 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpMatchValue:
+			pattern := vm.pop()
+			value := vm.pop()
+			err := vm.push(vm.nativeBoolToBooleanObject(matchValue(pattern, value)))
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array, ok := vm.pop().(*object.Array)
+			matched := ok && len(array.Elements) == numElements
+			err := vm.push(vm.nativeBoolToBooleanObject(matched))
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchHash:
+			numKeys := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			matched := vm.matchHash(vm.sp-numKeys, vm.sp)
+			vm.sp = vm.sp - numKeys - 1
+			err := vm.push(vm.nativeBoolToBooleanObject(matched))
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetIndex:
+			op := code.Opcode(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip++
+
+			err := vm.executeSetIndex(op)
+			if err != nil {
+				return err
+			}
+
 		case code.OpCall:
 			numArgs := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip++
@@ -237,8 +509,21 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpTailCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			err := vm.executeTailCall(int(numArgs))
+			if err != nil {
+				return err
+			}
+
 		case code.OpReturnValue:
 			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				// a return in the main program ends it
+				return nil
+			}
 			frame := vm.popFrame()
 			vm.sp = frame.basePointer - 1
 			err := vm.push(returnValue)
@@ -247,6 +532,14 @@ func (vm *VM) Run() error {
 			}
 
 		case code.OpReturn:
+			if vm.framesIndex == 1 {
+				err := vm.push(Null)
+				if err != nil {
+					return err
+				}
+				vm.pop()
+				return nil
+			}
 			frame := vm.popFrame()
 			vm.sp = frame.basePointer - 1
 			err := vm.push(Null)
@@ -257,8 +550,11 @@ func (vm *VM) Run() error {
 		case code.OpGetBuiltin:
 			builtinIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip++
-			definition := object.Builtins[builtinIndex]
-			err := vm.push(definition.Builtin)
+			builtin := vm.builtins.Get(int(builtinIndex))
+			if builtin == nil {
+				return fmt.Errorf("undefined builtin %d", builtinIndex)
+			}
+			err := vm.push(builtin)
 			if err != nil {
 				return err
 			}
@@ -285,15 +581,35 @@ func (vm *VM) Run() error {
 	return nil
 }
 
+// pushVariable pushes the value of a variable, which is missing when
+// a program reads the variable in its own definition, as in let x = x
+func (vm *VM) pushVariable(value object.Object) error {
+	if value == nil {
+		return fmt.Errorf("variable used before it is defined")
+	}
+	return vm.push(value)
+}
+
 func (vm *VM) push(o object.Object) error {
-	if vm.sp >= StackSize {
-		return fmt.Errorf("stack overflow")
+	if vm.sp >= len(vm.stack) {
+		vm.growStack(vm.sp + 1)
 	}
 	vm.stack[vm.sp] = o
 	vm.sp++
 	return nil
 }
 
+// growStack grows the stack to hold at least size elements
+func (vm *VM) growStack(size int) {
+	n := 2 * len(vm.stack)
+	if n < size {
+		n = size
+	}
+	stack := make([]object.Object, n)
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
 func (vm *VM) pop() object.Object {
 	o := vm.stack[vm.sp-1]
 	vm.sp--
@@ -308,6 +624,8 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	switch {
 	case leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ:
 		return vm.executeBinaryIntegerOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return vm.executeBinaryFloatOperation(op, left, right)
 	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
 		return vm.executeBinaryStringOperation(op, left, right)
 	default:
@@ -331,13 +649,45 @@ func (vm *VM) executeBinaryIntegerOperation(
 	case code.OpMul:
 		result = leftValue * rightValue
 	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
 		result = leftValue / rightValue
+	case code.OpMod:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		result = leftValue % rightValue
 	default:
 		return fmt.Errorf("unknown integer operator: %d", op)
 	}
 	return vm.push(&object.Integer{Value: result})
 }
 
+func (vm *VM) executeBinaryFloatOperation(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+	var result float64
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv:
+		result = leftValue / rightValue
+	case code.OpMod:
+		result = math.Mod(leftValue, rightValue)
+	default:
+		return fmt.Errorf("unknown float operator: %d", op)
+	}
+	return vm.push(&object.Float{Value: result})
+}
+
 func (vm *VM) executeBinaryStringOperation(
 	op code.Opcode,
 	left, right object.Object,
@@ -350,7 +700,12 @@ func (vm *VM) executeBinaryStringOperation(
 	leftValue := left.(*object.String).Value
 	rightValue := right.(*object.String).Value
 
-	return vm.push(&object.String{Value: leftValue + rightValue})
+	result := &object.String{Value: leftValue + rightValue}
+	err := vm.allocate(object.SizeOf(result))
+	if err != nil {
+		return err
+	}
+	return vm.push(result)
 }
 
 func (vm *VM) executeComparison(op code.Opcode) error {
@@ -360,6 +715,9 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
 		return vm.executeIntegerComparison(op, left, right)
 	}
+	if isNumber(left) && isNumber(right) {
+		return vm.executeFloatComparison(op, left, right)
+	}
 
 	switch op {
 	case code.OpEqual:
@@ -386,6 +744,37 @@ func (vm *VM) executeIntegerComparison(
 		return vm.push(vm.nativeBoolToBooleanObject(leftValue != rightValue))
 	case code.OpGreaterThan:
 		return vm.push(vm.nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(vm.nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessThan:
+		return vm.push(vm.nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpLessThanOrEqual:
+		return vm.push(vm.nativeBoolToBooleanObject(leftValue <= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeFloatComparison(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(vm.nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(vm.nativeBoolToBooleanObject(leftValue != rightValue))
+	case code.OpGreaterThan:
+		return vm.push(vm.nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(vm.nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessThan:
+		return vm.push(vm.nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpLessThanOrEqual:
+		return vm.push(vm.nativeBoolToBooleanObject(leftValue <= rightValue))
 	default:
 		return fmt.Errorf("unknown operator: %d", op)
 	}
@@ -409,12 +798,15 @@ func (vm *VM) executeBangOperator(op code.Opcode) error {
 func (vm *VM) executeMinusOperator(op code.Opcode) error {
 	operand := vm.pop()
 
-	if operand.Type() != object.INTEGER_OBJ {
+	switch operand := operand.(type) {
+	case *object.Integer:
+		return vm.push(&object.Integer{Value: -operand.Value})
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
 		return fmt.Errorf("unsupported type for negation: %s",
 			operand.Type())
 	}
-	value := operand.(*object.Integer).Value
-	return vm.push(&object.Integer{Value: -value})
 
 }
 
@@ -452,7 +844,7 @@ func (vm *VM) executeIndexExpression(left, index object.Object) error {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return vm.executeArrayIndex(left, index)
-	case left.Type() == object.HASH_OBJ && index.Type() == object.INTEGER_OBJ:
+	case left.Type() == object.HASH_OBJ:
 		return vm.executeHashIndex(left, index)
 	default:
 		return fmt.Errorf("index operator not supported: %s", left.Type())
@@ -482,12 +874,108 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	return vm.push(pair.Value)
 }
 
+// matchHash reports whether the value below the keys from
+// startIndex to endIndex is a hash holding every key
+func (vm *VM) matchHash(startIndex, endIndex int) bool {
+	hash, ok := vm.stack[startIndex-1].(*object.Hash)
+	if !ok {
+		return false
+	}
+	for i := startIndex; i < endIndex; i++ {
+		key, ok := vm.stack[i].(object.Hashable)
+		if !ok {
+			return false
+		}
+		if _, ok := hash.Pairs[key.HashKey()]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchValue reports whether a literal pattern and a value are
+// of the same type and equal
+func matchValue(literal, value object.Object) bool {
+	l, ok := literal.(object.Hashable)
+	if !ok {
+		return false
+	}
+	v, ok := value.(object.Hashable)
+	return ok && l.HashKey() == v.HashKey()
+}
+
+// executeSetIndex stores the value on top of the stack into the
+// collection and index below it, combining it with the current
+// element first when op is the binary opcode of a compound assignment
+func (vm *VM) executeSetIndex(op code.Opcode) error {
+	value := vm.pop()
+	index := vm.pop()
+	left := vm.pop()
+
+	if op != 0 {
+		err := vm.executeIndexExpression(left, index)
+		if err != nil {
+			return err
+		}
+		err = vm.push(value)
+		if err != nil {
+			return err
+		}
+		err = vm.executeBinaryOperation(op)
+		if err != nil {
+			return err
+		}
+		value = vm.pop()
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return fmt.Errorf("index assignment not supported: %s[%s]",
+				left.Type(), index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return fmt.Errorf("index out of range: %d", i.Value)
+		}
+		left.Elements[i.Value] = value
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return fmt.Errorf("unusable as hash key: %s", index.Type())
+		}
+		hashKey := key.HashKey()
+		if _, ok := left.Pairs[hashKey]; !ok {
+			err := vm.allocate(object.HashPairSize)
+			if err != nil {
+				return err
+			}
+		}
+		left.Pairs[hashKey] = object.HashPair{Key: index, Value: value}
+	default:
+		return fmt.Errorf("index assignment not supported: %s", left.Type())
+	}
+	return vm.push(value)
+}
+
+// allocate counts size bytes allocated by the run
+func (vm *VM) allocate(size int64) error {
+	if vm.meter == nil {
+		return nil
+	}
+	return vm.meter.Allocate(size)
+}
+
 func (vm *VM) currentFrame() *Frame {
 	return vm.frames[vm.framesIndex-1]
 }
 
 func (vm *VM) pushFrame(f *Frame) {
-	vm.frames[vm.framesIndex] = f
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
 	vm.framesIndex++
 }
 
@@ -519,22 +1007,72 @@ func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 	}
 
 	frame := NewFrame(cl, vm.sp-numArgs)
+	// the main frame does not count towards the depth
+	if vm.framesIndex > vm.limits.CallDepth() {
+		return fmt.Errorf("maximum recursion depth exceeded in %s", frame.Name())
+	}
+	err := vm.allocate(object.FrameSize)
+	if err != nil {
+		return err
+	}
 	vm.pushFrame(frame)
+	if size := frame.basePointer + cl.Fn.NumLocals; size > len(vm.stack) {
+		vm.growStack(size)
+	}
 
+	// clear the locals so no cell left behind by an earlier
+	// frame is shared with this one
+	for i := vm.sp; i < frame.basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	return nil
+}
+
+// executeTailCall calls a closure in the frame of its caller, which
+// the call would return from. Other callees are called as by
+// OpCall, and the caller returns their result.
+func (vm *VM) executeTailCall(numArgs int) error {
+	cl, ok := vm.stack[vm.sp-1-numArgs].(*object.Closure)
+	if !ok || vm.framesIndex == 1 {
+		return vm.executeCall(numArgs)
+	}
+	if numArgs != cl.Fn.NumParameters {
+		return fmt.Errorf("wrong number of arguments: want=%d, got=%d",
+			cl.Fn.NumParameters, numArgs)
+	}
+
+	// move the callee and the arguments over those of the caller
+	frame := vm.currentFrame()
+	copy(vm.stack[frame.basePointer-1:], vm.stack[vm.sp-1-numArgs:vm.sp])
+	vm.sp = frame.basePointer + numArgs
+	frame.cl = cl
+	frame.ip = -1
+
+	if size := frame.basePointer + cl.Fn.NumLocals; size > len(vm.stack) {
+		vm.growStack(size)
+	}
+	// clear the locals so no cell of the caller is shared
+	// with the callee
+	for i := vm.sp; i < frame.basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
 	vm.sp = frame.basePointer + cl.Fn.NumLocals
 	return nil
 }
 
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
-	result := builtin.Fn(args...)
+	result := builtin.Fn(vm.context, args...)
 	vm.sp = vm.sp - numArgs - 1
-	if result != nil {
-		vm.push(result)
-	} else {
-		vm.push(Null)
+	if result == nil {
+		return vm.push(Null)
 	}
-	return nil
+	err := vm.allocate(object.SizeOf(result))
+	if err != nil {
+		return err
+	}
+	return vm.push(result)
 }
 
 func (vm *VM) pushClosure(constIndex int, numFree int) error {
@@ -547,11 +1085,41 @@ func (vm *VM) pushClosure(constIndex int, numFree int) error {
 	for i := 0; i < numFree; i++ {
 		free[i] = vm.stack[vm.sp-numFree+i]
 	}
+	vm.sp = vm.sp - numFree
 	closure := &object.Closure{Fn: function, Free: free}
+	err := vm.allocate(object.SizeOf(closure))
+	if err != nil {
+		return err
+	}
 
 	return vm.push(closure)
 }
 
+// deref returns the value held by a cell, or obj itself
+// when it is not a cell
+func deref(obj object.Object) object.Object {
+	if cell, ok := obj.(*object.Cell); ok {
+		return cell.Value
+	}
+	return obj
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat converts an Integer or Float to a float64
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj := obj.(type) {
 
@@ -2,13 +2,20 @@
 package vm
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"monkey/ast"
+	"monkey/code"
 	"monkey/compiler"
+	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestIntegerArithmetic(t *testing.T) {
@@ -34,6 +41,28 @@ func TestIntegerArithmetic(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5", 1.5},
+		{"1.5 + 2.25", 3.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 - 2.5", 7.5},
+		{"1.5 * 4", 6.0},
+		{"7 / 2.0", 3.5},
+		{"7 / 2", 3},
+		{"-2.5", -2.5},
+		{"2e3 / 4", 500.0},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1 == 1.0", true},
+		{"1.5 != 1.5", false},
+		{"0.1 + 0.2 > 0.3", true},
+	}
+
+	runVMTests(t, tests)
+}
+
 func TestBooleanExpressions(t *testing.T) {
 	tests := []vmTestCase{
 		{"true", true},
@@ -65,6 +94,34 @@ func TestBooleanExpressions(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestComparisonAndLogicalOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7.5 % 2", 1.5},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"2.5 >= 2", true},
+		{"1.5 <= 1", false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 2", 2},
+		{"0 || 2", 0},
+		{"if (false) { 1 } || 3", 3},
+		{"false && (1 / 0 == 0)", false},
+		{"true || (1 % 0 == 0)", true},
+		{"1 < 2 && 2 < 3 || false", true},
+		{"let x = 5; x > 0 && x < 10", true},
+	}
+
+	runVMTests(t, tests)
+}
+
 func TestConditionals(t *testing.T) {
 	tests := []vmTestCase{
 		{"if (true) { 10 }", 10},
@@ -82,6 +139,319 @@ func TestConditionals(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestAssignments(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x %= 4; x", 2},
+		{"let x = 1; let y = 1; x = y = 3; x + y", 6},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let i = 0; while (i < 5) { i += 1; }; i", 5},
+		{"let a = [1, 2, 3]; a[1] = 5; a", []int{1, 5, 3}},
+		{"let a = [1, 2, 3]; a[2] *= 10; a", []int{1, 2, 30}},
+		{"let a = [1, 2]; let b = a; b[0] = 9; a[0]", 9},
+		{"let h = {}; h[1] = 2; h[1] += 1; h[1]", 3},
+		{`let h = {"a": 1}; h["a"] = 2; h["b"] = 3; h["a"] + h["b"]`, 5},
+		{"let f = fn() { let x = 1; x = x + 1; x }; f()", 2},
+		{"let f = fn(n) { n += 1; n }; f(1)", 2},
+		{
+			`
+			let counter = fn() {
+				let count = 0;
+				fn() { count += 1; count }
+			};
+			let c = counter();
+			c(); c();
+			c()
+			`,
+			3,
+		},
+		{
+			`
+			let f = fn() {
+				let x = 1;
+				let set = fn(v) { x = v };
+				set(5);
+				x
+			};
+			f()
+			`,
+			5,
+		},
+		{
+			`
+			let f = fn() {
+				let x = 0;
+				let inc = fn() { fn() { x += 1 } };
+				let g = inc();
+				g(); g();
+				x
+			};
+			f()
+			`,
+			2,
+		},
+		{
+			`
+			let make = fn() {
+				let n = 0;
+				[fn() { n += 1 }, fn() { n }]
+			};
+			let a = make();
+			let b = make();
+			a[0](); a[0](); b[0]();
+			a[1]() * 10 + b[1]()
+			`,
+			21,
+		},
+		{
+			`
+			let f = fn(a) { let b = 1; fn() { a + b } };
+			let g = fn(a) { let b = 2; a + b };
+			let h = f(10);
+			g(20) + h()
+			`,
+			33,
+		},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestAssignmentRuntimeErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = [1]; a[3] = 1", "1:19: index out of range: 3"},
+		{`let s = "ab"; s[0] = "c"`, "1:20: index assignment not supported: STRING"},
+		{`let h = {}; h[fn() {}] = 1`, "1:24: unusable as hash key: CLOSURE"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none")
+		}
+		if err.Error() != tt.expected {
+			t.Fatalf("wrong VM error: want=%q, got=%q",
+				tt.expected, err)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; let sum = 0; while (i < 5) { let sum = sum + i; let i = i + 1; }; sum", 10},
+		{"let i = 0; while (i < 100000) { let i = i + 1; }; i", 100000},
+		{"let i = 0; while (true) { let i = i + 1; if (i == 3) { break; } }; i", 3},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 0) { continue; } let sum = sum + x; }; sum", 4},
+		{`let s = ""; for (k in {"b": 2, "a": 1}) { let s = s + k; }; s`, "ab"},
+		{"let n = 0; for (x in [1, 2]) { for (y in [1, 2, 3]) { if (y == 2) { break; } let n = n + 1; } }; n", 2},
+		{"let f = fn(xs) { let sum = 0; for (x in xs) { let sum = sum + x; }; sum }; f([1, 2, 3])", 6},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x; } } }; f()", 2},
+		{"if (true) { while (false) { } }", Null},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestLoopsInExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let q = 0; let s = 0; while (q < 5) { q += 1; let v = if (q == 2) { continue; } else { q }; s += v }; s", 13},
+		{"let q = 0; let s = []; while (q < 5) { q += 1; s = [q, if (q == 2) { continue } else { q }] }; s", []int{5, 5}},
+		{"let s = 0; for (x in [1, 2, 3]) { s = s + if (x == 2) { break } else { x } }; s", 1},
+		{"let s = 0; for (x in [1, 2, 3]) { s += len([x, {x: if (x == 2) { continue } else { x }}]) }; s", 4},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem(), tt.input)
+		// the operands of the expressions that were left are popped
+		if vm.sp != 0 {
+			t.Errorf("%q: stack not empty. sp=%d", tt.input, vm.sp)
+		}
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	program := parse("for (x in 5) { x }")
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err == nil {
+		t.Fatalf("expected VM error but resulted in none.")
+	}
+	expected := "1:1: cannot iterate over INTEGER"
+	if err.Error() != expected {
+		t.Fatalf("wrong VM error: want=%q, got=%q", expected, err)
+	}
+}
+
+func TestElseIf(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (1 > 2) { 10 } else if (1 < 2) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (1 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (1 > 3) { 20 }", Null},
+		{"let f = fn(n) { if (n < 0) { -1 } else if (n == 0) { 0 } else { 1 } }; [f(-5), f(0), f(5)]", []int{-1, 0, 1}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestMatch(t *testing.T) {
+	tests := []vmTestCase{
+		{"match (1) { 1 => 10, 2 => 20 }", 10},
+		{"match (2) { 1 => 10, 2 => 20 }", 20},
+		{"match (3) { 1 => 10, 2 => 20 }", Null},
+		{"match (3) { 1 => 10, n => n * 100 }", 300},
+		{"match (-1) { -1 => 1, _ => 2 }", 1},
+		{"match (1.5) { 1 => 1, 1.5 => 2 }", 2},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{"match (true) { false => 1, true => 2 }", 2},
+		{`match (1) { "1" => 1, true => 2, _ => 3 }`, 3},
+		{"match ([1, 2]) { [a] => a, [a, b, c] => a, [a, b] => a + b }", 3},
+		{"match ([1, [2, 3]]) { [1, [x, 4]] => 0, [1, [x, 3]] => x }", 2},
+		{"match ([1, 2]) { [_, _] => 5 }", 5},
+		{`match ({"x": 1, "y": 2}) { {"z": z} => z, {"x": x, "y": y} => x * 10 + y }`, 12},
+		{`match ({"k": [1, 2]}) { {"k": [a, b]} => a + b }`, 3},
+		{"match ({}) { {} => 1 }", 1},
+		{"match (5) { [a] => 1, {} => 2, _ => 3 }", 3},
+		{"let f = fn(xs) { match (xs) { [] => 0, [x] => x, _ => -1 } }; [f([]), f([7]), f([1, 2])]", []int{0, 7, -1}},
+		{"let f = fn(n) { match (n) { 0 => 1, _ => n * f(n - 1) } }; f(5)", 120},
+		{"let x = 1; match (5) { x => x }; x", 5},
+		{"match (1) { a => match (a + 1) { b => a + b } }", 3},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestMacros(t *testing.T) {
+	input := `
+	let unless = macro(condition, consequence, alternative) {
+		quote(if (!(unquote(condition))) {
+			unquote(consequence);
+		} else {
+			unquote(alternative);
+		});
+	};
+	let square = macro(x) { quote(unquote(x) * unquote(x)) };
+	unless(1 > 2, square(3 + 1), 0);
+	`
+	program := parse(input)
+	env := object.NewEnvironment()
+	evaluator.DefineMacros(program, env)
+	expanded, err := evaluator.ExpandMacros(program, env)
+	if err != nil {
+		t.Fatalf("macro expansion failed: %s", err)
+	}
+
+	comp := compiler.New()
+	err = comp.Compile(expanded)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 16, vm.LastPoppedStackElem(), input)
+}
+
+func TestMarshaledBytecode(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			let fibonacci = fn(x) {
+				if (x < 2) { return x; }
+				fibonacci(x - 1) + fibonacci(x - 2)
+			};
+			fibonacci(15);
+			`,
+			610,
+		},
+		{
+			`
+			let counter = fn() { let n = 0; fn() { n += 1; n } };
+			let next = counter();
+			next(); next(); next();
+			`,
+			3,
+		},
+		{`let half = 1.5; half * 2.0`, 3.0},
+		{`"m" + "bc"`, "mbc"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		data, err := compiler.Marshal(comp.Bytecode())
+		if err != nil {
+			t.Fatalf("marshal error: %s", err)
+		}
+		bytecode, err := compiler.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("unmarshal error: %s", err)
+		}
+
+		vm := New(bytecode)
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem(), tt.input)
+	}
+}
+
+func TestMarshaledBytecodeWrongTypes(t *testing.T) {
+	// bytecode that Unmarshal accepts, as it does not know the types
+	// of the values on the stack, makes the VM fail instead of panic
+	ins := append(code.Make(code.OpTrue), code.Make(code.OpIteratorNext, 4)...)
+	data, err := compiler.Marshal(&compiler.Bytecode{Instructions: ins})
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+	bytecode, err := compiler.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unmarshal error: %s", err)
+	}
+
+	err = New(bytecode).Run()
+	if err == nil {
+		t.Fatalf("expected error, got none")
+	}
+	expected := "not an iterator: BOOLEAN"
+	if err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, err.Error())
+	}
+}
+
 func TestGlobalLetStatements(t *testing.T) {
 	tests := []vmTestCase{
 		{"let one = 1; one", 1},
@@ -398,13 +768,13 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 			input: `
 			fn() { 1; }(1);
 			`,
-			expected: `wrong number of arguments: want=0, got=1`,
+			expected: `2:15: wrong number of arguments: want=0, got=1`,
 		},
 		{
 			input: `
 			fn(a, b) { a + b; }(1);
 			`,
-			expected: `wrong number of arguments: want=2, got=1`,
+			expected: `2:23: wrong number of arguments: want=2, got=1`,
 		},
 	}
 
@@ -473,11 +843,50 @@ func TestBuiltinFunctions(t *testing.T) {
 				Message: "argument to 'push' must be ARRAY, got=INTEGER",
 			},
 		},
+		{
+			`gets(1)`,
+			&object.Error{
+				Message: "wrong number of arguments, got=1, want=0",
+			},
+		},
 	}
 
 	runVMTests(t, tests)
 }
 
+func TestBuiltinContext(t *testing.T) {
+	input := `
+	let greet = fn(name) { puts("hello " + name); };
+	greet(gets());
+	eputs(gets(), 2);
+	puts(gets());
+	`
+	comp := compiler.New()
+	err := comp.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	vm := New(comp.Bytecode())
+	vm.SetContext(&object.Context{
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Stdin:  strings.NewReader("monkey\r\nwarning\n"),
+	})
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if stdout.String() != "hello monkey\nnull\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+	if stderr.String() != "warning\n2\n" {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+}
+
 func TestClosures(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -635,6 +1044,301 @@ func TestRecursiveFibonacci(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestRuntimeErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"1 + true",
+			"1:3: unsupported types for binary operation: INTEGER BOOLEAN",
+		},
+		{
+			"let f = fn(x) {\n  let y = 1;\n  x - \"a\"\n};\nf(1);",
+			"3:5: unsupported types for binary operation: INTEGER STRING",
+		},
+		{
+			"let x = 1;\nx();",
+			"2:2: calling non-function and non-built-in",
+		},
+		{
+			"true && 1 % 0",
+			"1:11: division by zero",
+		},
+		{
+			"let x = x + 1;",
+			"1:9: variable used before it is defined",
+		},
+		{
+			"let f = fn() { let y = [y]; y };\nf();",
+			"1:25: variable used before it is defined",
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none")
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q",
+				tt.expected, err)
+		}
+	}
+}
+
+func TestRuntimeErrorFileLocation(t *testing.T) {
+	program := parse("let a = [1, 2];\na[0] + \"b\";")
+	comp := compiler.New()
+	comp.SetFile("script.mk")
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	expected := "script.mk:2:6: unsupported types for binary operation: INTEGER STRING"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong VM error: want=%q, got=%v", expected, err)
+	}
+}
+
+func TestRuntimeErrorStackTraces(t *testing.T) {
+	input := `let inner = fn(x) {
+  x + true
+};
+let outer = fn(y) {
+  inner(y)
+};
+outer(1);`
+
+	program := parse(input)
+	comp := compiler.New()
+	comp.SetFile("trace.mk")
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	runtimeErr, ok := err.(*object.RuntimeError)
+	if !ok {
+		t.Fatalf("error is not RuntimeError, got=%T (%+v)", err, err)
+	}
+
+	// outer calls inner in tail position, so inner replaces its frame
+	expected := `Traceback (most recent call last):
+  File "trace.mk", line 7, column 6, in <main>
+  File "trace.mk", line 2, column 5, in inner
+unsupported types for binary operation: INTEGER BOOLEAN`
+	if runtimeErr.Traceback() != expected {
+		t.Errorf("wrong traceback, expected=\n%s\ngot=\n%s",
+			expected, runtimeErr.Traceback())
+	}
+}
+
+func TestRecursionDepth(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   object.Limits
+		expected string
+	}{
+		{"let down = fn(n) { if (n == 0) { 0 } else { 1 + down(n - 1) } };\ndown(5000)",
+			object.Limits{}, ""},
+		{"let f = fn(n) { 1 + f(n + 1) };\nf(0)", object.Limits{MaxDepth: 10},
+			`Traceback (most recent call last):
+  line 2, column 2, in <main>
+  line 1, column 22, in f
+  line 1, column 22, in f
+  line 1, column 22, in f
+  [previous frame repeated 7 more times]
+maximum recursion depth exceeded in f`},
+		{"let f = fn(n) { 1 + f(n + 1) };\nf(0)", object.Limits{}, "maximum recursion depth exceeded in f"},
+		{"fn() { let g = fn() { 1 + g() }; 1 + g() }()", object.Limits{MaxDepth: 3},
+			`Traceback (most recent call last):
+  line 1, column 43, in <main>
+  line 1, column 39, in <anonymous>
+  line 1, column 28, in g
+  line 1, column 28, in g
+maximum recursion depth exceeded in g`},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		vm := New(comp.Bytecode())
+		vm.SetLimits(tt.limits)
+		err = vm.Run()
+
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error: %s", tt.input, err)
+			}
+			continue
+		}
+		runtimeErr, ok := err.(*object.RuntimeError)
+		if !ok {
+			t.Errorf("%q: error is not RuntimeError, got=%T (%+v)", tt.input, err, err)
+			continue
+		}
+		if !strings.HasSuffix(runtimeErr.Traceback(), tt.expected) {
+			t.Errorf("%q: wrong traceback, expected=\n%s\ngot=\n%s",
+				tt.input, tt.expected, runtimeErr.Traceback())
+		}
+		if tt.limits.MaxDepth == 0 && len(runtimeErr.Stack) != object.DefaultMaxDepth+1 {
+			t.Errorf("%q: wrong stack depth. want=%d, got=%d",
+				tt.input, object.DefaultMaxDepth+1, len(runtimeErr.Stack))
+		}
+	}
+}
+
+func TestReturnInMainProgram(t *testing.T) {
+	tests := []vmTestCase{
+		{"return 5; 6", 5},
+		{"let x = 1; if (x == 1) { return 10 }; 20", 10},
+		{"let i = 0; while (true) { i += 1; if (i == 3) { return i } }; 0", 3},
+		{"for (x in [1, 2]) { return x * 7 }", 7},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let loop = fn(i, acc) { if (i == 0) { acc } else { loop(i - 1, acc + i) } };\n" +
+			"loop(100000, 0)", "5000050000"},
+		{"let odd = 0;\n" +
+			"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };\n" +
+			"odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };\n" +
+			"even(100001)", "false"},
+		{"let sum = fn(arr, acc) { if (len(arr) == 0) { acc } else { sum(rest(arr), acc + first(arr)) } };\n" +
+			"let a = []; let i = 0; while (i < 1000) { a = push(a, i); i += 1 };\n" +
+			"sum(a, 0)", "499500"},
+		{"let f = fn(n) { while (true) { if (n == 0) { return \"done\" }; return f(n - 1) } };\n" +
+			"f(100000)", "done"},
+		{"let f = fn(n) { n > 100000 || f(n + 1) };\nf(0)", "true"},
+		{"let collect = fn(i, fs) { if (i == 3) { fs } else { " +
+			"let j = i; let g = fn() { j }; j = j * 10; collect(i + 1, push(fs, g)) } };\n" +
+			"let fs = collect(0, []); [fs[0](), fs[1](), fs[2]()]", "[0, 10, 20]"},
+		{"let f = fn(a) { len(a) };\nf([1, 2])", "2"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } };\nf(5)", "5"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		vm := New(comp.Bytecode())
+		// tail calls run in constant frame space
+		vm.SetLimits(object.Limits{MaxDepth: 10})
+		err = vm.Run()
+		if err != nil {
+			t.Errorf("%q: vm error: %s", tt.input, err)
+			continue
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("%q: wrong result. want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestOptimizedPrograms(t *testing.T) {
+	inputs := []string{
+		"1 + 2 * 3 - 4 / 2 % 3",
+		"-(5 - 10) * 2",
+		`"mon" + "key" + "!"`,
+		"!true == !!false",
+		"1 < 2 && 3 >= 4 || !0",
+		"if (2 > 1) { 10 } else { 20 }",
+		"if (1 > 2) { 10 }",
+		`let x = 5; [x * 1, 1 * x, x / 1, x + 0, 0 + x, x - 0, 2.5 * 1, -0.0 + 0]`,
+		"let f = fn(n) { if (true) { n * (2 - 1) + 0 } else { 0 } }; f(7)",
+		"let x = 1; true && x; false || x",
+		"let g = fn(n) { if (n == 0) { \"done\" } else { g(n - 1) } }; g(3)",
+		"1 / (2 - 2)",
+		"(1 / 0) * 1",
+		"0 + -(5 % 0) - 0",
+		"-true",
+		`"a" - "b"`,
+		`let x = "a"; x + 0`,
+		`let x = "a"; 0 + x`,
+		`let x = "a"; x * 1`,
+		"[1] - 0",
+		"let x = {}; x / 1",
+	}
+
+	for _, input := range inputs {
+		results := [2]string{}
+		for i, optimize := range []bool{false, true} {
+			comp := compiler.New()
+			comp.SetOptimize(optimize)
+			err := comp.Compile(parse(input))
+			if err != nil {
+				t.Fatalf("%q: compiler error: %s", input, err)
+			}
+			vm := New(comp.Bytecode())
+			err = vm.Run()
+			if err != nil {
+				results[i] = "error: " + err.Error()
+			} else {
+				results[i] = vm.LastPoppedStackElem().Inspect()
+			}
+		}
+		if results[0] != results[1] {
+			t.Errorf("%q: optimized program differs. want=%s, got=%s",
+				input, results[0], results[1])
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	program := parse("let f = fn(x) {\n  x * 2\n};\nf(1) + f(2);")
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	profile := map[int]int{}
+	vm := New(comp.Bytecode())
+	vm.SetTracer(func(op code.Opcode, location code.Location) {
+		profile[location.Span.Start.Line]++
+	})
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	// OpGetLocal, OpConstant, OpMul, OpReturnValue once per call
+	if profile[2] != 8 {
+		t.Errorf("wrong instruction count for line 2. want=8, got=%d",
+			profile[2])
+	}
+	// OpClosure, OpSetGlobal
+	if profile[1] != 2 {
+		t.Errorf("wrong instruction count for line 1. want=2, got=%d",
+			profile[1])
+	}
+}
+
 type vmTestCase struct {
 	input    string
 	expected interface{}
@@ -698,6 +1402,11 @@ func testExpectedObject(
 		if err != nil {
 			t.Errorf("testIntegerObject failed: %s (%s)", err, input)
 		}
+	case float64:
+		err := testFloatObject(expected, actual)
+		if err != nil {
+			t.Errorf("testFloatObject failed: %s (%s)", err, input)
+		}
 	case bool:
 		err := testBooleanObject(expected, actual)
 		if err != nil {
@@ -782,6 +1491,19 @@ func testIntegerObject(expected int64, actual object.Object) error {
 	return nil
 }
 
+func testFloatObject(expected float64, actual object.Object) error {
+	result, ok := actual.(*object.Float)
+	if !ok {
+		return fmt.Errorf("object is not Float, got=%T (%+v)",
+			actual, actual)
+	}
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. want=%g, got=%g",
+			expected, result.Value)
+	}
+	return nil
+}
+
 func testBooleanObject(expected bool, actual object.Object) error {
 	result, ok := actual.(*object.Boolean)
 	if !ok {
@@ -807,3 +1529,152 @@ func testStringObject(expected string, actual object.Object) error {
 	}
 	return nil
 }
+
+func TestCall(t *testing.T) {
+	comp := compiler.New()
+	err := comp.Compile(parse(`
+	let add = fn(a, b) { a + b };
+	let fail = fn() { 1 / 0 };
+	`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+	globals := make([]object.Object, GlobalSize)
+	err = NewWithGlobalsStore(bytecode, globals).Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	vm := NewWithGlobalsStore(&compiler.Bytecode{Constants: bytecode.Constants}, globals)
+	result, err := vm.Call(globals[0], &object.Integer{Value: 2}, &object.Integer{Value: 3})
+	if err != nil {
+		t.Fatalf("Call error: %s", err)
+	}
+	testExpectedObject(t, 5, result, "add(2, 3)")
+
+	result, err = vm.Call(object.GetBuiltinByName("len"), &object.String{Value: "abc"})
+	if err != nil {
+		t.Fatalf("Call error: %s", err)
+	}
+	testExpectedObject(t, 3, result, `len("abc")`)
+
+	_, err = vm.Call(globals[1])
+	runtimeErr, ok := err.(*object.RuntimeError)
+	if !ok || runtimeErr.Err.Error() != "division by zero" {
+		t.Fatalf("expected division by zero, got %v", err)
+	}
+	if len(runtimeErr.Stack) != 2 || runtimeErr.Stack[0].Function != "fail" {
+		t.Errorf("wrong stack. got=%+v", runtimeErr.Stack)
+	}
+
+	_, err = vm.Call(globals[0], &object.Integer{Value: 2})
+	if err == nil || !strings.Contains(err.Error(), "wrong number of arguments") {
+		t.Errorf("expected wrong number of arguments, got %v", err)
+	}
+}
+
+func TestBuiltinRegistry(t *testing.T) {
+	r := object.NewRegistry()
+	r.RegisterFunc("double", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+	symbolTable := compiler.NewSymbolTable()
+	symbolTable.DefineBuiltins(r)
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	err := comp.Compile(parse("double(len([1, 2, 3]))"))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	vm.SetBuiltins(r)
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 6, vm.LastPoppedStackElem(), "double(len([1, 2, 3]))")
+
+	// the standard builtins have no double
+	vm = New(comp.Bytecode())
+	err = vm.Run()
+	if err == nil || !strings.Contains(err.Error(), "undefined builtin") {
+		t.Errorf("expected undefined builtin error, got %v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		limits   object.Limits
+		expected error
+	}{
+		{"let i = 0; while (i < 10) { i += 1 }", context.Background(),
+			object.Limits{MaxInstructions: 1000}, nil},
+		{"while (true) { }", context.Background(),
+			object.Limits{MaxInstructions: 1000}, object.ErrInstructionLimit},
+		{"let f = fn() { while (true) { } }; f()", context.Background(),
+			object.Limits{Timeout: 10 * time.Millisecond}, object.ErrTimeout},
+		{"while (true) { }", canceled, object.Limits{}, context.Canceled},
+		{"let a = []; let i = 0; while (i < 10) { a = push(a, i); i += 1 }",
+			context.Background(), object.Limits{MaxMemory: 1 << 20}, nil},
+		{"let a = []; while (true) { a = push(a, 1) }", context.Background(),
+			object.Limits{MaxMemory: 1 << 20}, object.ErrMemoryLimit},
+		{"let s = \"ab\"; while (true) { s = s + s }", context.Background(),
+			object.Limits{MaxMemory: 1 << 20}, object.ErrMemoryLimit},
+		{"let f = fn(n) { {n: [n]} }; while (true) { f(1) }", context.Background(),
+			object.Limits{MaxMemory: 1 << 20}, object.ErrMemoryLimit},
+		{"let h = {}; let i = 0; while (true) { h[i] = i; i += 1 }", context.Background(),
+			object.Limits{MaxMemory: 1 << 20}, object.ErrMemoryLimit},
+		{"let h = {}; let i = 0; while (i < 100000) { h[0] = i; i += 1 }", context.Background(),
+			object.Limits{MaxMemory: 1 << 10}, nil},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		vm := New(comp.Bytecode())
+		vm.SetLimits(tt.limits)
+		err = vm.RunContext(tt.ctx)
+
+		if tt.expected == nil {
+			if err != nil {
+				t.Errorf("%q: unexpected error: %s", tt.input, err)
+			}
+			continue
+		}
+		abortErr, ok := err.(*object.AbortError)
+		if !ok || !errors.Is(err, tt.expected) {
+			t.Errorf("%q: wrong error. want=%v, got=%T (%v)", tt.input, tt.expected, err, err)
+			continue
+		}
+		if len(abortErr.Stack) == 0 || abortErr.Stack[len(abortErr.Stack)-1].Function != "<main>" {
+			t.Errorf("%q: wrong stack. got=%+v", tt.input, abortErr.Stack)
+		}
+	}
+}
+
+func TestLimitsCancelRunningProgram(t *testing.T) {
+	comp := compiler.New()
+	err := comp.Compile(parse("while (true) { }"))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err = New(comp.Bytecode()).RunContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}